@@ -0,0 +1,799 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// cssGroupRules 内部包含普通规则的 @ 规则，按内部规则是否被用到决定是否保留
+var cssGroupRules = map[string]bool{
+	"media":          true,
+	"supports":       true,
+	"document":       true,
+	"-moz-document":  true,
+	"container":      true,
+	"layer":          true,
+	"scope":          true,
+	"starting-style": true,
+}
+
+// CSSRule CSS 规则树节点
+type CSSRule struct {
+	kind     string     `label:"节点类型：rule 普通规则、at 带块的 @ 规则、statement 以分号结束的语句、comment 注释"`
+	name     string     `label:"@ 规则的小写名称"`
+	prelude  string     `label:"选择器或 @ 规则的条件"`
+	body     string     `label:"声明块内容"`
+	children []*CSSRule `label:"@media 等规则内部的规则"`
+}
+
+// isGroup 是否为内部包含普通规则的 @ 规则
+func (r *CSSRule) isGroup() bool {
+	return "at" == r.kind && cssGroupRules[r.name]
+}
+
+// String 按缩进与换行符输出规则
+func (r *CSSRule) String(indent string, separator string) string {
+	var items []string
+	var body = r.body
+
+	switch r.kind {
+	case "comment":
+		return indent + r.prelude
+	case "statement":
+		return indent + r.prelude + ";"
+	}
+	if !r.isGroup() {
+		// 写在一行的声明块去掉首尾空白，在 @media 等规则内部时加上一级缩进
+		if !strings.Contains(body, "\n") {
+			if body = strings.TrimSpace(body); "" != indent {
+				body = indent + "    " + body
+			}
+		}
+
+		return indent + r.prelude + " {" + separator + body + separator + indent + "}"
+	}
+
+	items = make([]string, 0, len(r.children))
+	for _, child := range r.children {
+		items = append(items, child.String(indent+"    ", separator))
+	}
+
+	return indent + r.prelude + " {" + separator + strings.Join(items, separator+separator) + separator + indent + "}"
+}
+
+// skipCSSString 返回字符串结束引号之后的位置，pos 为开始引号的位置
+func skipCSSString(data string, pos int) int {
+	var quote = data[pos]
+
+	for pos++; pos < len(data); pos++ {
+		if '\\' == data[pos] {
+			pos++
+		} else if quote == data[pos] {
+			return pos + 1
+		}
+	}
+
+	return len(data)
+}
+
+// skipCSSComment 返回注释结束之后的位置，pos 为注释开始的位置
+func skipCSSComment(data string, pos int) int {
+	if end := strings.Index(data[pos+2:], "*/"); end >= 0 {
+		return pos + 2 + end + 2
+	}
+
+	return len(data)
+}
+
+// scanCSS 跳过字符串、注释与括号，返回第一个不在其中的 stops 字符的位置，没有时返回数据长度
+func scanCSS(data string, pos int, stops string) int {
+	var depth int
+
+	for pos < len(data) {
+		switch {
+		case '"' == data[pos] || '\'' == data[pos]:
+			pos = skipCSSString(data, pos)
+
+			continue
+		case '/' == data[pos] && pos+1 < len(data) && '*' == data[pos+1]:
+			pos = skipCSSComment(data, pos)
+
+			continue
+		case '(' == data[pos]:
+			depth++
+		case ')' == data[pos] && depth > 0:
+			depth--
+		case 0 == depth && strings.IndexByte(stops, data[pos]) >= 0:
+			return pos
+		}
+
+		pos++
+	}
+
+	return pos
+}
+
+// matchBrace 返回与 pos 之前的 { 配对的 } 的位置，跳过字符串、注释与嵌套的块
+func matchBrace(data string, pos int) int {
+	var depth = 1
+
+	for pos < len(data) {
+		if pos = scanCSS(data, pos, "{}"); pos >= len(data) {
+			break
+		}
+		if '{' == data[pos] {
+			depth++
+		} else if depth--; 0 == depth {
+			return pos
+		}
+
+		pos++
+	}
+
+	return len(data)
+}
+
+// stripCSSComments 去掉选择器中的注释
+func stripCSSComments(data string) string {
+	var end int
+	var buf = new(strings.Builder)
+
+	for pos := 0; pos < len(data); pos = end {
+		switch {
+		case '"' == data[pos] || '\'' == data[pos]:
+			end = skipCSSString(data, pos)
+			buf.WriteString(data[pos:end])
+		case '/' == data[pos] && pos+1 < len(data) && '*' == data[pos+1]:
+			end = skipCSSComment(data, pos)
+		default:
+			end = pos + 1
+			buf.WriteByte(data[pos])
+		}
+	}
+
+	return buf.String()
+}
+
+// trimCSSBody 去掉声明块开头的换行与末尾的空白，保留每行的缩进
+func trimCSSBody(body string) string {
+	return strings.TrimRight(strings.TrimLeft(body, "\r\n"), "\r\n\t ")
+}
+
+// parseCSSBlock 解析 pos 开始的规则列表，nested 为 true 时解析到配对的 } 为止，返回规则列表与结束位置
+func parseCSSBlock(data string, pos int, nested bool) ([]*CSSRule, int) {
+	var end int
+	var prelude string
+	var rule *CSSRule
+	var rules = make([]*CSSRule, 0, 100)
+
+	for pos < len(data) {
+		if strings.IndexByte(" \t\r\n\f", data[pos]) >= 0 {
+			pos++
+
+			continue
+		}
+		if strings.HasPrefix(data[pos:], "/*") {
+			end = skipCSSComment(data, pos)
+			rules = append(rules, &CSSRule{kind: "comment", prelude: strings.TrimRight(data[pos:end], "\r\n\t ")})
+			pos = end
+
+			continue
+		}
+		if '}' == data[pos] {
+			if nested {
+				return rules, pos + 1
+			}
+
+			pos++
+
+			continue
+		}
+
+		end = scanCSS(data, pos, "{;}")
+		prelude = strings.Trim(stripCSSComments(data[pos:end]), " \t\r\n\f")
+		if end >= len(data) {
+			break
+		}
+
+		switch data[end] {
+		case ';':
+			if "" != prelude {
+				rules = append(rules, &CSSRule{kind: "statement", prelude: prelude})
+			}
+
+			pos = end + 1
+		case '}':
+			// 没有声明块的残缺内容直接丢弃，由下一轮处理 }
+			pos = end
+		case '{':
+			if rule = (&CSSRule{kind: "rule", prelude: prelude}); strings.HasPrefix(prelude, "@") {
+				rule.kind = "at"
+				rule.name = strings.ToLower(prelude[1:strings.IndexAny(prelude+" ", " \t\r\n\f({")])
+			}
+
+			if rule.isGroup() {
+				rule.children, pos = parseCSSBlock(data, end+1, true)
+			} else {
+				pos = matchBrace(data, end+1)
+				rule.body = trimCSSBody(data[end+1 : pos])
+				pos++
+			}
+
+			// 没有声明的普通规则不输出
+			if "" != prelude && ("at" == rule.kind || "" != rule.body) {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	return rules, pos
+}
+
+// parseCSS 解析 CSS 文件内容为规则树，同时返回文件使用的换行符
+func parseCSS(data string) ([]*CSSRule, string) {
+	var rules, _ = parseCSSBlock(data, 0, false)
+	var separator = "\r"
+
+	if strings.Contains(data, "\r\n") {
+		separator = "\r\n"
+	} else if strings.Contains(data, "\n") {
+		separator = "\n"
+	}
+
+	return rules, separator
+}
+
+// filterCSSRules 按选择器是否被用到筛选规则，@media 等规则只要有内部规则被用到就保留，否则整个丢弃
+func filterCSSRules(rules []*CSSRule, used func(selector string) bool) []*CSSRule {
+	var hasRule bool
+	var ret = make([]*CSSRule, 0, len(rules))
+
+	for _, rule := range rules {
+		switch {
+		case "rule" == rule.kind:
+			if !used(rule.prelude) {
+				continue
+			}
+
+			hasRule = true
+		case rule.isGroup():
+			var group = *rule
+			if group.children = filterCSSRules(rule.children, used); 0 == len(group.children) {
+				continue
+			}
+
+			hasRule = true
+			rule = &group
+		case "comment" != rule.kind:
+			hasRule = true
+		}
+
+		ret = append(ret, rule)
+	}
+
+	// 只剩注释时不保留，避免输出空的 @media
+	if !hasRule {
+		return nil
+	}
+
+	return ret
+}
+
+// cssDynamicPseudos 与用户操作或元素状态有关的伪类，检查选择器是否被用到时忽略
+var cssDynamicPseudos = map[string]bool{
+	"hover":             true,
+	"active":            true,
+	"focus":             true,
+	"focus-within":      true,
+	"focus-visible":     true,
+	"visited":           true,
+	"link":              true,
+	"any-link":          true,
+	"target":            true,
+	"checked":           true,
+	"disabled":          true,
+	"enabled":           true,
+	"default":           true,
+	"indeterminate":     true,
+	"valid":             true,
+	"invalid":           true,
+	"required":          true,
+	"optional":          true,
+	"read-only":         true,
+	"read-write":        true,
+	"placeholder-shown": true,
+	"before":            true,
+	"after":             true,
+	"first-line":        true,
+	"first-letter":      true,
+}
+
+// CSSUnevaluated 无法检查而保留的规则
+type CSSUnevaluated struct {
+	Selector string `label:"选择器"`
+	Reason   string `label:"无法检查的原因"`
+}
+
+// CSSUsageReport CSS 选择器检查报告
+type CSSUsageReport struct {
+	Rules       int               `label:"检查的规则数"`
+	Kept        int               `label:"保留的规则数"`
+	Dropped     []string          `label:"丢弃的规则选择器"`
+	Unevaluated []*CSSUnevaluated `label:"无法检查而保留的规则"`
+}
+
+// cssSelectorItem 选择器列表中的一个选择器
+type cssSelectorItem struct {
+	used bool         `label:"是否被用到"`
+	text string       `label:"选择器原文"`
+	err  string       `label:"无法检查的原因"`
+	sel  *TagSelector `label:"去掉动态伪类后的选择器"`
+}
+
+// cssIDRegex 选择器中的 ID，用于无法解析的选择器
+var cssIDRegex = regexp.MustCompile(`#(-?[A-Za-z_][\w-]*)`)
+
+// CSSUnstyled 源文件中用到但没有 CSS 规则的类名、ID 或标签
+type CSSUnstyled struct {
+	Name  string   `label:"名称"`
+	Count int      `label:"出现次数"`
+	Words []string `label:"用到的词头示例"`
+}
+
+// CSSUnstyledReport 源文件中用到但没有 CSS 规则的类名、ID 与标签
+type CSSUnstyledReport struct {
+	Class []*CSSUnstyled `label:"没有样式的类名"`
+	ID    []*CSSUnstyled `label:"没有样式的 ID"`
+	Tag   []*CSSUnstyled `label:"没有样式的标签"`
+}
+
+// CSSSummary 源文件选择器概览
+type CSSSummary struct {
+	Usage    map[string]map[string]int `label:"源文件中的标签、类名、ID 与属性的出现次数"`
+	Unstyled *CSSUnstyledReport        `label:"没有样式的类名、ID 与标签"`
+}
+
+// cssUsage 按源文件的标签概览与 DOM 树检查选择器是否被用到
+type cssUsage struct {
+	opt       *CSSOption                  `label:"CSS 整理选项"`
+	inUse     map[string]map[string]int   `label:"源文件标签概览"`
+	skipID    map[string]bool             `label:"忽略的 ID"`
+	skipClass map[string]bool             `label:"忽略的类名"`
+	skipAttr  map[string]bool             `label:"概览中没有记录的属性"`
+	items     map[string]*cssSelectorItem `label:"全部选择器"`
+	styled    map[string]map[string]bool  `label:"CSS 规则中出现的类名、ID 与标签"`
+	report    *CSSUsageReport             `label:"检查报告"`
+}
+
+// splitSelectors 按字符串与括号外的逗号拆分选择器列表
+func splitSelectors(selector string) []string {
+	var end int
+	var ret = make([]string, 0, 2)
+
+	for pos := 0; pos <= len(selector); pos = end + 1 {
+		end = scanCSS(selector, pos, ",")
+		if item := strings.TrimSpace(selector[pos:end]); "" != item {
+			ret = append(ret, item)
+		}
+	}
+
+	return ret
+}
+
+// stripDynamicPseudo 去掉选择器中的伪元素与动态伪类，all 为 true 时去掉全部伪类，去掉后复合选择器为空时用 * 代替
+func stripDynamicPseudo(text string, all bool) string {
+	var end int
+	var name string
+	var element bool
+	var buf = new(strings.Builder)
+
+	for pos := 0; pos < len(text); pos = end {
+		switch text[pos] {
+		case '"', '\'':
+			end = skipCSSString(text, pos)
+			buf.WriteString(text[pos:end])
+
+			continue
+		case '[':
+			if end = scanCSS(text, pos, "]") + 1; end > len(text) {
+				end = len(text)
+			}
+
+			buf.WriteString(text[pos:end])
+
+			continue
+		case ':':
+		default:
+			end = pos + 1
+			buf.WriteByte(text[pos])
+
+			continue
+		}
+
+		if end = pos + 1; end < len(text) && ':' == text[end] {
+			end++
+		}
+
+		element = end-pos > 1
+		for ; end < len(text) && ('-' == text[end] || '_' == text[end] || (text[end] >= '0' && text[end] <= '9') || (text[end]|0x20 >= 'a' && text[end]|0x20 <= 'z')); end++ {
+		}
+
+		name = strings.ToLower(strings.TrimLeft(text[pos:end], ":"))
+		if end < len(text) && '(' == text[end] {
+			if end = scanCSS(text, end+1, ")") + 1; end > len(text) {
+				end = len(text)
+			}
+		}
+		if !element && !all && !cssDynamicPseudos[name] {
+			buf.WriteString(text[pos:end])
+
+			continue
+		}
+
+		// 伪类前没有其它选择器时补上 *，如 .a > :hover
+		if prev := strings.TrimRight(buf.String(), " \t\r\n\f"); "" == prev || strings.IndexByte(">+~(", prev[len(prev)-1]) >= 0 || len(prev) < buf.Len() {
+			buf.WriteByte('*')
+		}
+	}
+
+	return buf.String()
+}
+
+// newCSSUsage 收集规则树中的全部选择器
+func newCSSUsage(opt *CSSOption, inUse map[string]map[string]int, rules []*CSSRule) *cssUsage {
+	var u = &cssUsage{
+		opt:       opt,
+		inUse:     inUse,
+		skipID:    make(map[string]bool, len(opt.SkipID)),
+		skipClass: make(map[string]bool, len(opt.SkipClass)),
+		skipAttr:  make(map[string]bool, len(opt.SkipAttr)),
+		items:     make(map[string]*cssSelectorItem, 1000),
+		styled:    map[string]map[string]bool{"class": {}, "id": {}, "tag": {}},
+		report:    &CSSUsageReport{Dropped: make([]string, 0, 100), Unevaluated: make([]*CSSUnevaluated, 0, 10)},
+	}
+
+	for _, v := range opt.SkipID {
+		u.skipID[v] = true
+	}
+	for _, v := range opt.SkipClass {
+		u.skipClass[v] = true
+	}
+	for _, v := range opt.SkipAttr {
+		u.skipAttr[strings.ToLower(v)] = true
+	}
+
+	u.add(rules)
+
+	return u
+}
+
+// add 解析规则中的选择器，忽略的类名与 ID 从选择器中去掉
+//
+// 格式不正确或使用不支持的伪类的选择器记为无法检查，但去掉全部伪类后按标签概览一定不会匹配的选择器可以确定没有被用到
+func (u *cssUsage) add(rules []*CSSRule) {
+	var err error
+	var sel *TagSelector
+	var item *cssSelectorItem
+
+	for _, rule := range rules {
+		if rule.isGroup() {
+			u.add(rule.children)
+		}
+		if "rule" != rule.kind {
+			continue
+		}
+
+		for _, text := range splitSelectors(rule.prelude) {
+			if _, ok := u.items[text]; ok {
+				continue
+			}
+
+			item = &cssSelectorItem{text: text}
+			if item.sel, err = ParseSelector(stripDynamicPseudo(text, false)); nil == err {
+				u.reference(item.sel)
+				u.skip(item.sel)
+			} else if sel, _ = ParseSelector(stripDynamicPseudo(text, true)); nil != sel {
+				u.reference(sel)
+				if u.skip(sel); u.possible(sel) {
+					item.err = err.Error()
+				}
+			} else {
+				item.err = err.Error()
+				for _, v := range cssClassRegex.FindAllStringSubmatch(text, -1) {
+					u.styled["class"][v[1]] = true
+				}
+				for _, v := range cssIDRegex.FindAllStringSubmatch(text, -1) {
+					u.styled["id"][v[1]] = true
+				}
+			}
+
+			u.items[text] = item
+		}
+	}
+}
+
+// reference 记录选择器中出现的类名、ID 与标签，包括 :not() 中的选择器
+func (u *cssUsage) reference(sel *TagSelector) {
+	for _, complex := range sel.Items {
+		for _, part := range complex.Parts {
+			if "" != part.Tag && "*" != part.Tag {
+				u.styled["tag"][part.Tag] = true
+			}
+			for _, v := range part.IDs {
+				u.styled["id"][v] = true
+			}
+			for _, v := range part.Classes {
+				u.styled["class"][v] = true
+			}
+			for _, v := range part.Attrs {
+				if "class" == v.Name && "" != v.Value {
+					u.styled["class"][v.Value] = true
+				} else if "id" == v.Name && "" != v.Value {
+					u.styled["id"][v.Value] = true
+				}
+			}
+			for _, v := range part.Pseudos {
+				if nil != v.Not {
+					u.reference(v.Not)
+				}
+			}
+		}
+	}
+}
+
+// skip 从选择器中去掉忽略的类名与 ID
+func (u *cssUsage) skip(sel *TagSelector) {
+	for _, complex := range sel.Items {
+		for _, part := range complex.Parts {
+			var ids = part.IDs[:0]
+			var classes = part.Classes[:0]
+
+			for _, v := range part.IDs {
+				if !u.skipID[v] {
+					ids = append(ids, v)
+				}
+			}
+			for _, v := range part.Classes {
+				if !u.skipClass[v] {
+					classes = append(classes, v)
+				}
+			}
+
+			part.IDs, part.Classes = ids, classes
+		}
+	}
+}
+
+// possible 按标签概览检查选择器中的每个复合选择器是否都有可能匹配，概览中没有的标签、类名、ID 或属性一定不会被用到
+func (u *cssUsage) possible(sel *TagSelector) bool {
+	for _, complex := range sel.Items {
+		var ok = true
+
+		for _, part := range complex.Parts {
+			if ok = u.possiblePart(part); !ok {
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// possiblePart 按标签概览检查复合选择器是否有可能匹配
+func (u *cssUsage) possiblePart(part *CompoundSelector) bool {
+	if "" != part.Tag && "*" != part.Tag && 0 == u.inUse["tag"][part.Tag] {
+		return false
+	}
+	for _, v := range part.IDs {
+		if 0 == u.inUse["id"][v] {
+			return false
+		}
+	}
+	for _, v := range part.Classes {
+		if 0 == u.inUse["class"][v] {
+			return false
+		}
+	}
+	for _, v := range part.Attrs {
+		if u.skipAttr[v.Name] || "class" == v.Name {
+			continue
+		}
+
+		var values = u.inUse[v.Name]
+		if 0 == len(values) {
+			return false
+		}
+		if "=" == v.Op && !v.Fold && "id" != v.Name && 0 == values[v.Value] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchSource 逐条解析源文件的 DOM 树，用标签的上级与兄弟元素检查概览中有可能匹配的选择器，全部匹配后提前结束
+func (u *cssUsage) matchSource() error {
+	var err error
+	var chunk []byte
+	var dom *Dom
+	var element *Entry
+	var reader *EntryReader
+	var pending = make([]*cssSelectorItem, 0, len(u.items))
+
+	for _, item := range u.items {
+		if nil != item.sel && u.possible(item.sel) {
+			if u.opt.Quick {
+				item.used = true
+			} else {
+				pending = append(pending, item)
+			}
+		}
+	}
+	if 0 == len(pending) {
+		return nil
+	}
+
+	if reader, err = OpenEntryReader(u.opt.Source); nil != err {
+		return err
+	}
+	defer reader.Close()
+
+	for len(pending) > 0 {
+		if chunk, _, err = reader.Next(); nil != err {
+			break
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element || "" != element.action {
+			continue
+		}
+
+		dom = parseBodyItem(element, string(chunk))
+		for _, tag := range dom.root {
+			if !tag.state || !tag.isElement() {
+				continue
+			}
+
+			for k := 0; k < len(pending); k++ {
+				if dom.Match(tag, pending[k].sel) {
+					pending[k].used = true
+					pending = append(pending[:k], pending[k+1:]...)
+					k--
+				}
+			}
+		}
+	}
+	if io.EOF == err {
+		err = nil
+	}
+
+	return err
+}
+
+// used 规则的选择器列表中有选择器被用到时保留规则，没有被用到但有无法检查的选择器时也保留，并记入报告
+func (u *cssUsage) used(selector string) bool {
+	var reason string
+
+	u.report.Rules++
+	for _, text := range splitSelectors(selector) {
+		if item := u.items[text]; item.used {
+			u.report.Kept++
+
+			return true
+		} else if "" != item.err && "" == reason {
+			reason = item.err
+		}
+	}
+	if "" != reason {
+		u.report.Kept++
+		u.report.Unevaluated = append(u.report.Unevaluated, &CSSUnevaluated{Selector: selector, Reason: reason})
+
+		return true
+	}
+
+	u.report.Dropped = append(u.report.Dropped, selector)
+
+	return false
+}
+
+// unstyled 返回源文件中用到但没有 CSS 规则的类名、ID 与标签，按出现次数从多到少排序，每项最多带 samples 个词头示例
+//
+// 实现思路：
+//
+//	1、标签概览中的类名、ID 与标签没有在任何选择器中出现，也不在忽略列表中时记为没有样式
+//	2、属性选择器 [class~=x]、[id=x] 中的值也算作出现
+//	3、有没有样式的项目时再读取一遍源文件，按 DOM 树记录用到这些项目的词头，示例都收集满后提前结束
+func (u *cssUsage) unstyled(samples int) (*CSSUnstyledReport, error) {
+	var err error
+	var num int
+	var chunk []byte
+	var dom *Dom
+	var element *Entry
+	var reader *EntryReader
+	var report = new(CSSUnstyledReport)
+	var index = map[string]map[string]*CSSUnstyled{"class": {}, "id": {}, "tag": {}}
+	var lists = map[string]*[]*CSSUnstyled{"class": &report.Class, "id": &report.ID, "tag": &report.Tag}
+
+	for kind, list := range lists {
+		*list = make([]*CSSUnstyled, 0, 10)
+		for name, count := range u.inUse[kind] {
+			if u.styled[kind][name] || ("class" == kind && u.skipClass[name]) || ("id" == kind && u.skipID[name]) || "" == name {
+				continue
+			}
+
+			index[kind][name] = &CSSUnstyled{Name: name, Count: count, Words: make([]string, 0, samples)}
+			*list = append(*list, index[kind][name])
+		}
+
+		var items = *list
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].Count != items[j].Count {
+				return items[i].Count > items[j].Count
+			}
+
+			return items[i].Name < items[j].Name
+		})
+		num += len(items)
+	}
+	if 0 == num || samples < 1 {
+		return report, nil
+	}
+
+	if reader, err = OpenEntryReader(u.opt.Source); nil != err {
+		return report, err
+	}
+	defer reader.Close()
+
+	for num > 0 {
+		if chunk, _, err = reader.Next(); nil != err {
+			break
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element || "" != element.action {
+			continue
+		}
+
+		var found = make(map[*CSSUnstyled]bool, 10)
+		dom = parseBodyItem(element, string(chunk))
+		for _, tag := range dom.root {
+			if !tag.state || !tag.isElement() {
+				continue
+			}
+
+			found[index["tag"][strings.ToLower(tag.name)]] = true
+			if attr := tag.Get("id"); nil != attr {
+				found[index["id"][attr.value]] = true
+			}
+			if attr := tag.Get("class"); nil != attr {
+				for _, v := range strings.Fields(attr.value) {
+					found[index["class"][v]] = true
+				}
+			}
+		}
+		for item := range found {
+			if nil != item && len(item.Words) < samples {
+				if item.Words = append(item.Words, element.word); len(item.Words) == samples {
+					num--
+				}
+			}
+		}
+	}
+	if io.EOF == err {
+		err = nil
+	}
+
+	return report, err
+}
+
+// Save 保存选择器检查报告
+func (r *CSSUsageReport) Save(file string) error {
+	var data, err = json.MarshalIndent(r, "", "    ")
+	if nil != err {
+		return err
+	}
+
+	return FilePutContents(file, data, false)
+}
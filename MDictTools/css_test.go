@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// formatCSS 按 css 入口保存文件的方式输出规则
+func formatCSS(rules []*CSSRule, separator string) string {
+	var items = make([]string, 0, len(rules))
+
+	for _, rule := range rules {
+		items = append(items, rule.String("", separator))
+	}
+
+	return strings.Join(items, separator+separator)
+}
+
+func TestParseCSSRoundTrip(t *testing.T) {
+	var cases = []struct {
+		name string
+		data string
+	}{
+		{"rule", ".a {\n    color: red;\n    margin: 0;\n}"},
+		{"one line", ".a {\ncolor: red\n}\n\n.b, .c > p {\nmargin: 0\n}"},
+		{"statement", "@charset \"utf-8\";\n\n@import url(\"a{b}.css\");"},
+		{"comment", "/* { not a rule } */\n\n.a {\ncolor: red\n}"},
+		{"string brace", ".a::before {\n    content: \"}\";\n    color: red;\n}\n\n.b::after {\n    content: '{';\n    color: blue;\n}"},
+		{"attr brace", "a[title=\"x{y}\"] {\ncolor: red\n}"},
+		{"url brace", ".a {\n    background: url(a}b.png);\n    color: red;\n}"},
+		{"media", "@media screen and (max-width: 600px) {\n    .a {\n        color: red;\n    }\n\n    .b {\n        content: \"}\";\n    }\n}"},
+		{"nested media", "@supports (display: grid) {\n    @media print {\n        .a {\n            display: grid;\n        }\n    }\n}"},
+		{"font face", "@font-face {\n    font-family: \"x\";\n    src: url(x.woff);\n}"},
+		{"keyframes", "@keyframes spin {\n    from { transform: rotate(0deg); }\n    to { transform: rotate(360deg); }\n}"},
+		{"crlf", ".a {\r\ncolor: red;\r\n}\r\n\r\n@media print {\r\n    .b {\r\n        color: blue;\r\n    }\r\n}"},
+	}
+
+	for _, v := range cases {
+		var rules, separator = parseCSS(v.data)
+		var got = formatCSS(rules, separator)
+
+		// 用例均为输出格式，解析后原样输出
+		if got != v.data {
+			t.Errorf("%s: 输出 %q，应为 %q", v.name, got, v.data)
+		}
+	}
+}
+
+func TestParseCSSTree(t *testing.T) {
+	var data = "/* a { b } */\n.a { content: \"}\"; }\n@media print { .b { color: red } /* c */ .c { } }\n@import \"x;y\";\n.d { }"
+	var rules, separator = parseCSS(data)
+
+	if "\n" != separator {
+		t.Fatalf("换行符为 %q，应为 \\n", separator)
+	}
+	if 4 != len(rules) {
+		t.Fatalf("解析出 %d 条规则，应为 4 条", len(rules))
+	}
+	if "comment" != rules[0].kind || "/* a { b } */" != rules[0].prelude {
+		t.Errorf("第 1 条规则为 %s %q，应为注释", rules[0].kind, rules[0].prelude)
+	}
+	if "rule" != rules[1].kind || ".a" != rules[1].prelude || "content: \"}\";" != strings.TrimSpace(rules[1].body) {
+		t.Errorf("第 2 条规则为 %s %q {%q}", rules[1].kind, rules[1].prelude, rules[1].body)
+	}
+	if !rules[2].isGroup() || "media" != rules[2].name || 2 != len(rules[2].children) {
+		t.Fatalf("第 3 条规则应为包含 2 条内部规则的 @media")
+	}
+	if ".b" != rules[2].children[0].prelude || "comment" != rules[2].children[1].kind {
+		t.Errorf("@media 内部规则为 %q、%s，没有声明的 .c 应丢弃", rules[2].children[0].prelude, rules[2].children[1].kind)
+	}
+	if "statement" != rules[3].kind || "@import \"x;y\"" != rules[3].prelude {
+		t.Errorf("第 4 条规则为 %s %q，应为 @import 语句", rules[3].kind, rules[3].prelude)
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DiffOption 词典源文件比较选项
+type DiffOption struct {
+	Old    string `label:"旧词典源文件"`
+	New    string `label:"新词典源文件"`
+	Report string `label:"文本报告文件"`
+	HTML   string `label:"HTML 报告文件"`
+}
+
+// diffEntry 参与比较的词条
+type diffEntry struct {
+	key  string `label:"词头与重复序号"`
+	word string `label:"词头"`
+	data string `label:"词条内容"`
+}
+
+// diffOp 比较结果片段，op 为 = 相同、- 删除、+ 新增
+type diffOp struct {
+	op   byte   `label:"操作"`
+	text string `label:"内容"`
+}
+
+// diffResult 词条比较结果
+type diffResult struct {
+	op   byte      `label:"操作"`
+	word string    `label:"词头"`
+	old  string    `label:"旧词条内容"`
+	new  string    `label:"新词条内容"`
+	ops  []*diffOp `label:"正文差异"`
+}
+
+// diffLimit 逐个标记比较的最大计算量，超过时整体视为替换
+const diffLimit = 4000000
+
+// Init 检查比较选项
+func (o *DiffOption) Init() error {
+	var err error
+	var msg = make([]string, 0, 2)
+
+	if "" == o.Old {
+		msg = append(msg, "旧词典源文件属性 Old 不能为空")
+	} else if _, err = os.Stat(o.Old); nil != err {
+		msg = append(msg, "旧词典源文件 "+o.Old+" 不存在")
+	}
+	if "" == o.New {
+		msg = append(msg, "新词典源文件属性 New 不能为空")
+	} else if _, err = os.Stat(o.New); nil != err {
+		msg = append(msg, "新词典源文件 "+o.New+" 不存在")
+	} else {
+		var base = o.New
+		if pos := strings.LastIndex(o.New, "."); pos > 0 {
+			base = o.New[:pos]
+		}
+		if "" == o.Report {
+			o.Report = base + ".diff.txt"
+		}
+		if "" == o.HTML {
+			o.HTML = base + ".diff.html"
+		}
+	}
+
+	if len(msg) > 0 {
+		err = errors.New(strings.Join(msg, "\n"))
+	}
+
+	return err
+}
+
+// loadDiffEntries 读取词典源文件，重复词头按出现顺序加上序号
+func loadDiffEntries(file string) ([]*diffEntry, error) {
+	var err error
+	var chunk []byte
+	var element *Entry
+	var reader *EntryReader
+	var seen = make(map[string]int, 100000)
+	var entries = make([]*diffEntry, 0, 100000)
+
+	if reader, err = OpenEntryReader(file); nil != err {
+		return nil, err
+	}
+	defer reader.Close()
+
+	for {
+		if chunk, _, err = reader.Next(); nil != err {
+			break
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element {
+			continue
+		}
+
+		seen[element.word]++
+		entries = append(entries, &diffEntry{
+			key:  element.word + "\x00" + strconv.Itoa(seen[element.word]),
+			word: element.word,
+			data: stripSpace(string(chunk)),
+		})
+	}
+	if io.EOF != err {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// diffTokens 把词条正文拆分为比较标记，标签为一个标记，文本按单词、空白与单个非字母字符拆分
+func diffTokens(word string, data string) []string {
+	var tokens = make([]string, 0, 100)
+	var dom = parseBodyItem(&Entry{word: word}, data)
+
+	for k, tag := range dom.root {
+		if !tag.state {
+			continue
+		}
+
+		// 第一个文本以词头开头，只比较词头之后的内容
+		if 0 == k && ("content" == tag.category || "raw" == tag.category) {
+			if pos := strings.IndexAny(tag.value, "\r\n"); -1 != pos {
+				tokens = append(tokens, splitText(strings.Trim(tag.value[pos:], "\r\n"))...)
+			}
+		} else if "content" == tag.category {
+			tokens = append(tokens, splitText(tag.value)...)
+		} else {
+			tokens = append(tokens, tag.String())
+		}
+	}
+
+	return tokens
+}
+
+// splitText 按单词、连续空白与单个其他字符拆分文本
+func splitText(text string) []string {
+	var start = -1
+	var kind, last int
+	var tokens = make([]string, 0, 10)
+
+	for k, v := range text {
+		switch {
+		case unicode.IsSpace(v):
+			kind = 1
+		case v < 0x2e80 && (unicode.IsLetter(v) || unicode.IsDigit(v)):
+			kind = 2
+		default:
+			kind = 3
+		}
+
+		if -1 != start && (kind != last || 3 == kind) {
+			tokens = append(tokens, text[start:k])
+			start = -1
+		}
+		if -1 == start {
+			start = k
+		}
+
+		last = kind
+	}
+	if -1 != start {
+		tokens = append(tokens, text[start:])
+	}
+
+	return tokens
+}
+
+// diffTokenList 用最长公共子序列比较两组标记，合并相邻的同类片段
+func diffTokenList(a []string, b []string) []*diffOp {
+	var i, j, n, m int
+	var table []int32
+	var ops = make([]*diffOp, 0, 10)
+	var prefix, suffix int
+
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops = appendOp(ops, '=', a[:prefix])
+	n, m = len(a)-prefix-suffix, len(b)-prefix-suffix
+	if n*m > diffLimit {
+		ops = appendOp(ops, '-', a[prefix:prefix+n])
+		ops = appendOp(ops, '+', b[prefix:prefix+m])
+	} else {
+		// table[i*(m+1)+j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+		table = make([]int32, (n+1)*(m+1))
+		for i = n - 1; i >= 0; i-- {
+			for j = m - 1; j >= 0; j-- {
+				if a[prefix+i] == b[prefix+j] {
+					table[i*(m+1)+j] = table[(i+1)*(m+1)+j+1] + 1
+				} else if table[(i+1)*(m+1)+j] >= table[i*(m+1)+j+1] {
+					table[i*(m+1)+j] = table[(i+1)*(m+1)+j]
+				} else {
+					table[i*(m+1)+j] = table[i*(m+1)+j+1]
+				}
+			}
+		}
+
+		for i, j = 0, 0; i < n || j < m; {
+			if i < n && j < m && a[prefix+i] == b[prefix+j] {
+				ops = appendOp(ops, '=', a[prefix+i:prefix+i+1])
+				i++
+				j++
+			} else if j == m || (i < n && table[(i+1)*(m+1)+j] >= table[i*(m+1)+j+1]) {
+				ops = appendOp(ops, '-', a[prefix+i:prefix+i+1])
+				i++
+			} else {
+				ops = appendOp(ops, '+', b[prefix+j:prefix+j+1])
+				j++
+			}
+		}
+	}
+
+	return appendOp(ops, '=', a[len(a)-suffix:])
+}
+
+// appendOp 追加比较片段，与上一个片段操作相同时合并
+func appendOp(ops []*diffOp, op byte, tokens []string) []*diffOp {
+	if 0 == len(tokens) {
+		return ops
+	}
+	if len(ops) > 0 && op == ops[len(ops)-1].op {
+		ops[len(ops)-1].text += strings.Join(tokens, "")
+
+		return ops
+	}
+
+	return append(ops, &diffOp{op: op, text: strings.Join(tokens, "")})
+}
+
+// diffMdict 按词头比较两个词典源文件，生成文本报告与 HTML 报告
+//
+// 实现思路：
+//
+//	1、读取两个源文件，词头相同的词条按出现顺序一一对应
+//	2、只在新文件中的为新增，只在旧文件中的为删除，内容不同的为修改
+//	3、修改的词条把正文解析为 DOM 标记，标签作为整体参与比较，文本按单词拆分
+func diffMdict(cfg string) error {
+	var ok bool
+	var err error
+	var old *diffEntry
+	var oldEntries, newEntries []*diffEntry
+	var results = make([]*diffResult, 0, 1000)
+	var opt = new(DiffOption)
+	var mapper map[string]*diffEntry
+	var added, removed, changed, same int
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if oldEntries, err = loadDiffEntries(opt.Old); nil != err {
+		return err
+	}
+	if newEntries, err = loadDiffEntries(opt.New); nil != err {
+		return err
+	}
+
+	mapper = make(map[string]*diffEntry, len(oldEntries))
+	for _, v := range oldEntries {
+		mapper[v.key] = v
+	}
+	for _, v := range newEntries {
+		if old, ok = mapper[v.key]; !ok {
+			added++
+			results = append(results, &diffResult{op: '+', word: v.word, new: v.data})
+
+			continue
+		}
+
+		delete(mapper, v.key)
+		if old.data == v.data {
+			same++
+
+			continue
+		}
+
+		changed++
+		results = append(results, &diffResult{
+			op:   '~',
+			word: v.word,
+			old:  old.data,
+			new:  v.data,
+			ops:  diffTokenList(diffTokens(old.word, old.data), diffTokens(v.word, v.data)),
+		})
+	}
+	for _, v := range oldEntries {
+		if _, ok = mapper[v.key]; ok {
+			removed++
+			results = append(results, &diffResult{op: '-', word: v.word, old: v.data})
+		}
+	}
+
+	var summary = fmt.Sprintf("old: %d, new: %d, added: %d, removed: %d, changed: %d, unchanged: %d", len(oldEntries), len(newEntries), added, removed, changed, same)
+	fmt.Println(summary)
+
+	if err = writeDiffText(opt.Report, summary, results); nil != err {
+		return err
+	}
+
+	return writeDiffHTML(opt.HTML, summary, results)
+}
+
+// writeDiffText 保存文本报告，修改的词条逐段列出删除与新增的内容
+func writeDiffText(file string, summary string, results []*diffResult) error {
+	var err error
+	var fp *os.File
+	var buf *bufio.Writer
+
+	if fp, err = os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	buf = bufio.NewWriterSize(fp, 1<<20)
+	buf.WriteString(summary + "\n")
+	for _, v := range results {
+		buf.WriteString("\n" + string(v.op) + " " + v.word + "\n")
+		for _, op := range v.ops {
+			if '=' != op.op {
+				buf.WriteString("    " + string(op.op) + " " + strings.ReplaceAll(op.text, "\n", "\\n") + "\n")
+			}
+		}
+	}
+
+	return buf.Flush()
+}
+
+// writeDiffHTML 保存 HTML 报告，标签以源码形式显示，删除与新增的内容分别标记
+func writeDiffHTML(file string, summary string, results []*diffResult) error {
+	var err error
+	var fp *os.File
+	var buf *bufio.Writer
+	var names = map[byte]string{'+': "added", '-': "removed", '~': "changed"}
+
+	if fp, err = os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	buf = bufio.NewWriterSize(fp, 1<<20)
+	buf.WriteString(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>diff</title><style>
+body{font-family:sans-serif;margin:0;display:flex}
+nav{width:260px;height:100vh;overflow:auto;position:sticky;top:0;border-right:1px solid #ccc;font-size:13px}
+nav a{display:block;padding:2px 8px;text-decoration:none;white-space:nowrap;overflow:hidden;text-overflow:ellipsis}
+main{flex:1;padding:0 16px}
+section{border-bottom:1px solid #eee;padding:8px 0}
+pre{white-space:pre-wrap;word-break:break-all;font-size:13px;margin:4px 0}
+del{background:#fdd;color:#900}ins{background:#dfd;color:#060;text-decoration:none}
+.added h3{color:#060}.removed h3{color:#900}.changed h3{color:#036}
+.added a{color:#060}.removed a{color:#900}.changed a{color:#036}
+</style></head><body><nav>`)
+	for k, v := range results {
+		buf.WriteString(`<a class="` + names[v.op] + `" href="#e` + strconv.Itoa(k) + `">` + string(v.op) + " " + html.EscapeString(v.word) + "</a>")
+	}
+	buf.WriteString("</nav><main><p>" + html.EscapeString(summary) + "</p>\n")
+	for k, v := range results {
+		buf.WriteString(`<section class="` + names[v.op] + `" id="e` + strconv.Itoa(k) + `"><h3>` + html.EscapeString(v.word) + " (" + names[v.op] + ")</h3><pre>")
+		switch v.op {
+		case '+':
+			buf.WriteString("<ins>" + html.EscapeString(v.new) + "</ins>")
+		case '-':
+			buf.WriteString("<del>" + html.EscapeString(v.old) + "</del>")
+		default:
+			for _, op := range v.ops {
+				switch op.op {
+				case '+':
+					buf.WriteString("<ins>" + html.EscapeString(op.text) + "</ins>")
+				case '-':
+					buf.WriteString("<del>" + html.EscapeString(op.text) + "</del>")
+				default:
+					buf.WriteString(html.EscapeString(op.text))
+				}
+			}
+		}
+		buf.WriteString("</pre></section>\n")
+	}
+	buf.WriteString("</main></body></html>\n")
+
+	return buf.Flush()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DupCopy 重复词头的一个词条
+type DupCopy struct {
+	Offset int64  `label:"文件偏移"`
+	Size   int    `label:"词条字节数"`
+	Action string `label:"处理方式"`
+	Word   string `json:",omitempty" label:"改名后的词头"`
+}
+
+// DupGroup 重复词头的全部词条
+type DupGroup struct {
+	Word   string     `label:"词头"`
+	Copies []*DupCopy `label:"词条列表"`
+	seen   int        `label:"第二遍已读取的词条数"`
+}
+
+// DupReport 重复词头处理报告
+type DupReport struct {
+	Policy string               `label:"处理策略"`
+	Groups []*DupGroup          `label:"重复词头列表"`
+	first  map[string]*DupCopy  `label:"词头第一次出现的词条"`
+	groups map[string]*DupGroup `label:"重复词头索引"`
+}
+
+// newDupReport 创建重复词头处理报告
+func newDupReport(policy string) *DupReport {
+	return &DupReport{
+		Policy: policy,
+		Groups: make([]*DupGroup, 0, 10),
+		first:  make(map[string]*DupCopy, 100000),
+		groups: make(map[string]*DupGroup, 10),
+	}
+}
+
+// checkDupPolicy 检查重复词头处理策略
+func checkDupPolicy(policy string) error {
+	switch policy {
+	case "", "first", "last", "longest", "concat", "number":
+		return nil
+	}
+
+	return errors.New("重复词头处理策略 Duplicate 只能是 first、last、longest、concat、number")
+}
+
+// Add 记录一个非链接词条，第二次出现时建立重复词头分组
+func (r *DupReport) Add(word string, offset int64, size int) {
+	var item = &DupCopy{Offset: offset, Size: size}
+
+	if group, ok := r.groups[word]; ok {
+		group.Copies = append(group.Copies, item)
+	} else if first, ok := r.first[word]; ok {
+		group = &DupGroup{Word: word, Copies: []*DupCopy{first, item}}
+		r.groups[word] = group
+		r.Groups = append(r.Groups, group)
+	} else {
+		r.first[word] = item
+	}
+}
+
+// Decide 按处理策略决定每个重复词条的处理方式，words 为全部词头，用于避免编号后的词头与已有词头冲突
+//
+// 处理方式：keep 保留，drop 删除，concat 合并其余词条的正文后保留，merge 已合并到第一个词条，rename 改为编号词头
+func (r *DupReport) Decide(words map[string]bool, variant string) {
+	var n, keep int
+	var word string
+
+	r.first = nil
+	sort.Slice(r.Groups, func(i int, j int) bool {
+		return r.Groups[i].Copies[0].Offset < r.Groups[j].Copies[0].Offset
+	})
+
+	for _, group := range r.Groups {
+		keep = 0
+		switch r.Policy {
+		case "last":
+			keep = len(group.Copies) - 1
+		case "longest":
+			for k, v := range group.Copies {
+				if v.Size > group.Copies[keep].Size {
+					keep = k
+				}
+			}
+		}
+
+		n = 1
+		for k, v := range group.Copies {
+			switch {
+			case "" == r.Policy || k == keep:
+				v.Action = "keep"
+			case "concat" == r.Policy:
+				v.Action = "merge"
+			case "number" == r.Policy:
+				for n++; ; n++ {
+					word = strings.ReplaceAll(strings.ReplaceAll(variant, "{word}", group.Word), "{n}", strconv.Itoa(n))
+					if !words[word] {
+						break
+					}
+				}
+
+				v.Action = "rename"
+				v.Word = word
+				words[word] = true
+			default:
+				v.Action = "drop"
+			}
+		}
+		if "concat" == r.Policy {
+			group.Copies[0].Action = "concat"
+		}
+	}
+}
+
+// Next 返回第二遍读取到的重复词条的处理信息，不是重复词头或没有检查重复词头时返回 nil
+func (r *DupReport) Next(word string) (*DupGroup, *DupCopy) {
+	if nil == r {
+		return nil, nil
+	}
+
+	var group, ok = r.groups[word]
+	if !ok || group.seen >= len(group.Copies) {
+		return nil, nil
+	}
+
+	group.seen++
+
+	return group, group.Copies[group.seen-1]
+}
+
+// Save 保存重复词头处理报告
+func (r *DupReport) Save(file string) error {
+	var data, err = json.MarshalIndent(r, "", "    ")
+	if nil != err {
+		return err
+	}
+
+	return FilePutContents(file, data, false)
+}
+
+// Print 输出重复词头概况
+func (r *DupReport) Print() {
+	var num int
+
+	for _, group := range r.Groups {
+		num += len(group.Copies)
+	}
+
+	fmt.Println("duplicate words:", len(r.Groups), "entries:", num, "policy:", r.Policy)
+}
+
+// concatEntry 读取重复词头的其余词条，用分隔模板把正文追加到第一个词条
+func (o *TidyOption) concatEntry(fp *os.File, group *DupGroup, data string) (string, error) {
+	var pos int
+	var err error
+	var body string
+	var chunk []byte
+	var buf = new(strings.Builder)
+
+	buf.WriteString(data)
+	for k, v := range group.Copies[1:] {
+		chunk = make([]byte, v.Size)
+		if _, err = fp.ReadAt(chunk, v.Offset); nil != err {
+			return data, err
+		}
+		// 第一遍已执行预替换，直接使用读取的内容
+		if body = string(chunk); -1 == strings.IndexAny(body, "\r\n") {
+			continue
+		}
+
+		pos = strings.IndexAny(body, "\r\n")
+		buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(o.DupSeparator, "{word}", group.Word), "{n}", strconv.Itoa(k+2)))
+		buf.WriteString(strings.Trim(body[pos:], "\r\n"))
+	}
+
+	return buf.String(), nil
+}
+
+// renameEntry 修改词条的词头
+func renameEntry(data string, word string) string {
+	if pos := strings.IndexAny(data, "\r\n"); -1 != pos {
+		return word + data[pos:]
+	}
+
+	return word
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// textEncodings 支持的文本编码，UTF-8 不需要转换
+var textEncodings = map[string]encoding.Encoding{
+	"UTF-8":    nil,
+	"UTF-16":   unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"UTF-16LE": unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"UTF-16BE": unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	"GBK":      simplifiedchinese.GBK,
+	"GB2312":   simplifiedchinese.GBK,
+	"GB18030":  simplifiedchinese.GB18030,
+	"BIG5":     traditionalchinese.Big5,
+}
+
+// checkEncoding 检查并规范编码名称，空值表示自动识别
+func checkEncoding(field string, name string) (string, error) {
+	if name = strings.ToUpper(strings.TrimSpace(name)); "" == name {
+		return "", nil
+	}
+	if "UTF8" == name {
+		name = "UTF-8"
+	}
+	if _, ok := textEncodings[name]; !ok {
+		return name, errors.New("编码 " + field + " 只支持 UTF-8、UTF-16LE、UTF-16BE、GBK、GB18030 与 Big5")
+	}
+
+	return name, nil
+}
+
+// detectEncoding 识别文件编码，返回编码名称与 BOM 的字节数
+//
+// 实现思路：
+//
+//	1、文件带有 BOM 时以 BOM 为准
+//	2、没有 BOM 时使用配置的编码，没有配置时前几个字符的高位字节都为 0 按 UTF-16LE 处理，否则按 UTF-8 处理
+//	3、按 UTF-8 处理时检查文件开头的内容，不是有效的 UTF-8 时返回错误，避免输出乱码
+func detectEncoding(file string, name string) (string, int, error) {
+	var num int
+	var err error
+	var fp *os.File
+	var data = make([]byte, 1<<16)
+
+	if fp, err = os.Open(file); nil != err {
+		return name, 0, err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	if num, err = io.ReadFull(fp, data); nil != err && io.ErrUnexpectedEOF != err && io.EOF != err {
+		return name, 0, err
+	}
+
+	data = data[:num]
+	switch {
+	case bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}):
+		return "UTF-8", 3, nil
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		return "UTF-16LE", 2, nil
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		return "UTF-16BE", 2, nil
+	case "" == name && len(data) > 3 && 0 != data[0] && 0 == data[1] && 0 != data[2] && 0 == data[3]:
+		return "UTF-16LE", 0, nil
+	case "" == name:
+		name = "UTF-8"
+	}
+
+	if "UTF-8" == name {
+		// 只读取了文件开头时末尾可能是不完整的字符，去掉后再检查
+		for k := 0; num == cap(data) && k < utf8.UTFMax-1 && !utf8.Valid(data); k++ {
+			data = data[:len(data)-1]
+		}
+		if !utf8.Valid(data) {
+			return name, 0, errors.New("文件 " + file + " 不是有效的 UTF-8 编码，请通过 Encoding 指定文件编码")
+		}
+	}
+
+	return name, 0, nil
+}
+
+// decodeFile 把文件转换为 UTF-8 编码，已经是 UTF-8 时返回原文件，否则返回转换后的临时文件
+func decodeFile(file string, name string) (string, bool, error) {
+	var err error
+	var bom int
+	var in, out *os.File
+	var buf *bufio.Writer
+
+	if name, bom, err = detectEncoding(file, name); nil != err || nil == textEncodings[name] {
+		return file, false, err
+	}
+
+	if in, err = os.Open(file); nil != err {
+		return file, false, err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	if _, err = in.Seek(int64(bom), io.SeekStart); nil != err {
+		return file, false, err
+	}
+	if out, err = os.CreateTemp("", "mdict-*"+filepath.Ext(file)); nil != err {
+		return file, false, err
+	}
+
+	buf = bufio.NewWriterSize(out, 1<<20)
+	if _, err = io.Copy(buf, transform.NewReader(in, textEncodings[name].NewDecoder())); nil == err {
+		err = buf.Flush()
+	}
+	if cerr := out.Close(); nil == err {
+		err = cerr
+	}
+	if nil != err {
+		_ = os.Remove(out.Name())
+
+		return file, false, err
+	}
+
+	return out.Name(), true, nil
+}
+
+// encodeFile 把 UTF-8 编码的文件转换为指定编码，UTF-16 编码写入 BOM
+func encodeFile(file string, name string) error {
+	var err error
+	var in, out *os.File
+	var buf *bufio.Writer
+	var writer io.WriteCloser
+
+	if "" == name || nil == textEncodings[name] {
+		return nil
+	}
+
+	if in, err = os.Open(file); nil != err {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	if out, err = os.OpenFile(file+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return err
+	}
+
+	buf = bufio.NewWriterSize(out, 1<<20)
+	switch name {
+	case "UTF-16", "UTF-16LE":
+		_, _ = buf.Write([]byte{0xff, 0xfe})
+	case "UTF-16BE":
+		_, _ = buf.Write([]byte{0xfe, 0xff})
+	}
+	writer = transform.NewWriter(buf, textEncodings[name].NewEncoder())
+	if _, err = io.Copy(writer, in); nil == err {
+		err = writer.Close()
+	}
+	if nil == err {
+		err = buf.Flush()
+	}
+	if cerr := out.Close(); nil == err {
+		err = cerr
+	}
+	if nil != err {
+		_ = os.Remove(file + ".tmp")
+
+		return err
+	}
+
+	_ = in.Close()
+
+	return os.Rename(file+".tmp", file)
+}
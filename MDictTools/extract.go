@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// extractMark 生成的共享样式与脚本在 CSS、JS 文件中的起始标记
+const extractMark = "/* MDictTools extracted blocks */"
+
+// embeddedBlockRegex 词条中内嵌的样式与脚本块
+var embeddedBlockRegex = regexp.MustCompile(`(?is)<(style|script)(\s[^>]*)?>(.*?)</(style|script)\s*>`)
+
+// blockTypeRegex 只有 type 属性的样式与脚本标签属性
+var blockTypeRegex = regexp.MustCompile(`(?i)^\s*(type\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]*))?\s*/?\s*$`)
+
+// ExtractParam 内嵌样式与脚本提取参数，用于 Extract 动作
+type ExtractParam struct {
+	MinCount int    `label:"提取的最少出现词条数"`
+	CSS      string `label:"追加样式的 CSS 文件"`
+	JS       string `label:"追加脚本的 JS 文件"`
+	Link     string `label:"替换样式块的 link 地址"`
+	Script   string `label:"替换脚本块的 script 地址"`
+}
+
+// extractState 内嵌块的统计，扫描词条时记录，整理时只读
+type extractState struct {
+	counts map[string]int    `label:"内嵌块出现的词条数"`
+	blocks map[string]string `label:"内嵌块原始内容"`
+	order  []string          `label:"内嵌块出现顺序"`
+	shared map[string]bool   `label:"提取的内嵌块"`
+}
+
+// Init 检查提取参数
+func (p *ExtractParam) Init() error {
+	if "" == p.CSS && "" == p.JS {
+		return errors.New("动作 Extract 的参数 CSS 与 JS 不能同时为空")
+	}
+	if "" != p.JS && "" == p.Script {
+		// 删除脚本块后没有标签引用 JS 文件时脚本不再执行
+		return errors.New("动作 Extract 设置 JS 时必须设置 Script")
+	}
+	if p.MinCount < 1 {
+		p.MinCount = 2
+	}
+
+	return nil
+}
+
+// file 返回内嵌块提取到的文件，没有设置时返回空字符串
+func (p *ExtractParam) file(name string) string {
+	if "style" == name {
+		return p.CSS
+	}
+
+	return p.JS
+}
+
+// blockKey 内嵌块的比较键，合并空白后比较，避免整理空白后同一内容无法匹配
+func blockKey(name string, content string) string {
+	return name + "\x00" + strings.Join(strings.Fields(content), " ")
+}
+
+// countBlocks 统计词条中的内嵌块，同一内容在一个词条中只计一次
+func (r *TidyRule) countBlocks(body string) {
+	var seen = make(map[string]bool, 2)
+
+	for _, v := range embeddedBlockRegex.FindAllStringSubmatch(body, -1) {
+		var name = strings.ToLower(v[1])
+		if name != strings.ToLower(v[4]) || "" == r.extract.file(name) || "" == strings.TrimSpace(v[3]) || !blockTypeRegex.MatchString(v[2]) {
+			continue
+		}
+
+		var key = blockKey(name, v[3])
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		if _, ok := r.state.counts[key]; !ok {
+			r.state.order = append(r.state.order, key)
+			r.state.blocks[key] = strings.Trim(v[3], "\r\n")
+		}
+
+		r.state.counts[key]++
+	}
+}
+
+// saveBlocks 确定提取的内嵌块，按出现顺序追加到 CSS 与 JS 文件末尾的标记之后，重新执行时替换上次生成的内容
+func (r *TidyRule) saveBlocks() error {
+	var err error
+	var files = make(map[string][]string, 2)
+	var names = make([]string, 0, 2)
+
+	for _, key := range r.state.order {
+		if r.state.counts[key] < r.extract.MinCount {
+			continue
+		}
+
+		var file = r.extract.file(key[:strings.IndexByte(key, 0)])
+		if _, ok := files[file]; !ok {
+			names = append(names, file)
+		}
+
+		r.state.shared[key] = true
+		files[file] = append(files[file], r.state.blocks[key])
+	}
+
+	for _, file := range names {
+		if err = appendBlocks(file, files[file]); nil != err {
+			return err
+		}
+
+		fmt.Println("extract blocks:", len(files[file]), "->", file)
+	}
+
+	return nil
+}
+
+// appendBlocks 把内嵌块追加到文件末尾的标记之后
+func appendBlocks(file string, blocks []string) error {
+	var err error
+	var data []byte
+	var content string
+	var separator = "\n"
+
+	if data, err = os.ReadFile(file); nil != err && !os.IsNotExist(err) {
+		return err
+	}
+
+	content = string(data)
+	if pos := strings.Index(content, extractMark); pos >= 0 {
+		content = content[:pos]
+	}
+	if strings.Contains(content, "\r\n") {
+		separator = "\r\n"
+	}
+	if content = strings.TrimRight(content, "\r\n\t "); "" != content {
+		content += separator + separator
+	}
+
+	content += extractMark + separator + separator + strings.Join(blocks, separator+separator) + separator
+
+	return FilePutContents(file, []byte(content), false)
+}
+
+// extractBlocks 删除词条中已提取的内嵌块，每个词条第一个被删除的样式块与脚本块分别替换为一个 link 与 script 标签
+func (r *TidyRule) extractBlocks(d *Dom) {
+	var replaced = make(map[string]bool, 2)
+	var matched = make([]*Tag, 0, 2)
+
+	for _, tag := range d.root {
+		if tag.state && "start" == tag.category && ("style" == tag.name || "script" == tag.name) && tag.close > tag.id && d.Match(tag, r.sel) {
+			matched = append(matched, tag)
+		}
+	}
+
+	for _, tag := range matched {
+		var end = tag.close
+		var buf = new(strings.Builder)
+		var plain = true
+
+		if tag.hasAttr {
+			tag.Parse()
+			for _, attr := range tag.attrs {
+				plain = plain && (!attr.state || "type" == attr.lowerName)
+			}
+		}
+		for _, v := range d.root {
+			if v.state && v.id > tag.id && v.id < end {
+				buf.WriteString(v.String())
+			}
+		}
+		if !plain || !r.state.shared[blockKey(tag.name, buf.String())] {
+			continue
+		}
+
+		d.DropTag(tag)
+		if replaced[tag.name] {
+			continue
+		}
+
+		replaced[tag.name] = true
+		if "style" == tag.name && "" != r.extract.Link {
+			tag.value = "<link rel=\"stylesheet\" type=\"text/css\" href=\"" + strings.ReplaceAll(r.extract.Link, "\"", "&quot;") + "\"/>"
+		} else if "script" == tag.name && "" != r.extract.Script {
+			tag.value = "<script type=\"text/javascript\" src=\"" + strings.ReplaceAll(r.extract.Script, "\"", "&quot;") + "\"></script>"
+		} else {
+			continue
+		}
+
+		// 替换为自闭合的标签，原结束标签已删除
+		tag.state = true
+		tag.category = "self"
+		tag.dynamic = false
+		tag.close = 0
+		if "style" == tag.name {
+			tag.name = "link"
+		}
+	}
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ImportOption 外部词典导入选项
+type ImportOption struct {
+	Escape    bool   `label:"正文为纯文本，转义 HTML 特殊字符"`
+	Input     string `label:"外部词典文件"`
+	Output    string `label:"输出的词典源文件"`
+	Separator string `label:"多列正文的连接符"`
+}
+
+// sourceWriter 按 word\r\nbody\r\n</> 的格式写入词典源文件
+type sourceWriter struct {
+	num int           `label:"已写入的词条数"`
+	fp  *os.File      `label:"文件句柄"`
+	buf *bufio.Writer `label:"写入缓冲"`
+}
+
+// dslCommentRegex DSL 中的 {{...}} 注释
+var dslCommentRegex = regexp.MustCompile(`(?s)\{\{.*?\}\}`)
+
+// dslTags DSL 标签对应的 HTML 开始与结束标签，不在表中的 DSL 标签直接丢弃
+var dslTags = map[string][2]string{
+	"b":   {"<b>", "</b>"},
+	"i":   {"<i>", "</i>"},
+	"u":   {"<u>", "</u>"},
+	"sub": {"<sub>", "</sub>"},
+	"sup": {"<sup>", "</sup>"},
+	"p":   {`<span class="p">`, "</span>"},
+	"t":   {`<span class="t">`, "</span>"},
+	"ex":  {`<span class="ex">`, "</span>"},
+	"com": {`<span class="com">`, "</span>"},
+	"trn": {`<span class="trn">`, "</span>"},
+	"*":   {`<span class="opt">`, "</span>"},
+	"'":   {`<span class="stress">`, "</span>"},
+}
+
+// dslMediaExt 用 img 显示的 [s] 资源扩展名，其它扩展名按音频处理
+var dslMediaExt = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".bmp": true, ".svg": true, ".webp": true}
+
+// Init 检查导入选项，ext 为导入格式默认的输入文件扩展名
+func (o *ImportOption) Init(ext string) error {
+	var pos int
+	var err error
+
+	if "" == o.Input {
+		return errors.New("输入文件属性 Input 不能为空")
+	}
+	if _, err = os.Stat(o.Input); nil != err {
+		return errors.New("输入文件 " + o.Input + " 不存在")
+	}
+	if ext != strings.ToLower(filepath.Ext(o.Input)) && ".tsv" != ext {
+		return errors.New("输入文件 " + o.Input + " 的扩展名必须是 " + ext)
+	}
+
+	if "" == o.Output {
+		pos = len(o.Input) - len(filepath.Ext(o.Input))
+		o.Output = o.Input[:pos] + ".txt"
+	}
+	if filepath.Clean(o.Output) == filepath.Clean(o.Input) {
+		return errors.New("输出文件 Output 不能与输入文件相同")
+	}
+	if "" == o.Separator {
+		o.Separator = "<br/>"
+	}
+
+	return nil
+}
+
+// createSource 创建词典源文件
+func createSource(file string) (*sourceWriter, error) {
+	var fp, err = os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	if nil != err {
+		return nil, err
+	}
+
+	return &sourceWriter{fp: fp, buf: bufio.NewWriterSize(fp, 1<<20)}, nil
+}
+
+// Add 写入一个词条，词头为空时忽略
+func (w *sourceWriter) Add(word string, body string) error {
+	var err error
+
+	if word = strings.TrimSpace(word); "" == word {
+		return nil
+	}
+
+	w.num++
+	w.buf.WriteString(word)
+	w.buf.WriteString("\r\n")
+	w.buf.WriteString(body)
+	_, err = w.buf.WriteString("\r\n</>\r\n")
+
+	if 0 == w.num%50000 {
+		fmt.Println("start processed:", w.num)
+	}
+
+	return err
+}
+
+// Close 写入缓冲的数据并关闭文件
+func (w *sourceWriter) Close() error {
+	var err = w.buf.Flush()
+
+	if cerr := w.fp.Close(); nil == err {
+		err = cerr
+	}
+
+	return err
+}
+
+// readTextFile 读取文本文件，按 BOM 识别 UTF-8 与 UTF-16，没有 BOM 时按 UTF-8 处理
+func readTextFile(file string) (string, error) {
+	var data, err = os.ReadFile(file)
+	if nil != err {
+		return "", err
+	}
+
+	switch {
+	case len(data) > 1 && 0xff == data[0] && 0xfe == data[1]:
+		return decodeUTF16(data[2:]), nil
+	case len(data) > 1 && 0xfe == data[0] && 0xff == data[1]:
+		for k := 2; k+1 < len(data); k += 2 {
+			data[k], data[k+1] = data[k+1], data[k]
+		}
+
+		return decodeUTF16(data[2:]), nil
+	case len(data) > 2 && 0xef == data[0] && 0xbb == data[1] && 0xbf == data[2]:
+		return string(data[3:]), nil
+	}
+
+	return string(data), nil
+}
+
+// importTSV 将制表符分隔的词汇表导入为词典源文件
+//
+// 实现思路：
+//
+//	1、每行第一列为词头，其余各列用连接符合并为正文，空行与 # 开头的行忽略
+//	2、正文中的 \n、\t、\\ 转义还原，Escape 为 true 时转义 HTML 特殊字符并把换行改为 <br/>
+func importTSV(cfg string) error {
+	var err error
+	var text, body string
+	var cols []string
+	var out *sourceWriter
+	var opt = new(ImportOption)
+	var unescape = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\\`, `\`)
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(".tsv"); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if text, err = readTextFile(opt.Input); nil != err {
+		return err
+	}
+	if out, err = createSource(opt.Output); nil != err {
+		return err
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimRight(line, "\r"); "" == strings.TrimSpace(line) || '#' == line[0] {
+			continue
+		}
+		if cols = strings.Split(line, "\t"); len(cols) < 2 {
+			continue
+		}
+
+		for k := range cols[1:] {
+			if cols[k+1] = unescape.Replace(strings.TrimSpace(cols[k+1])); opt.Escape {
+				cols[k+1] = strings.ReplaceAll(html.EscapeString(cols[k+1]), "\n", "<br/>")
+			}
+		}
+
+		body = strings.Join(cols[1:], opt.Separator)
+		if err = out.Add(unescape.Replace(cols[0]), body); nil != err {
+			break
+		}
+	}
+
+	if cerr := out.Close(); nil == err {
+		err = cerr
+	}
+	if nil == err {
+		fmt.Println("import tsv, entries:", out.num)
+	}
+
+	return err
+}
+
+// importDSL 将 ABBYY Lingvo DSL 词典导入为词典源文件
+//
+// 实现思路：
+//
+//	1、按 BOM 识别编码后去除 {{...}} 注释，# 开头的文件头只读取词典名称
+//	2、顶格的行为词头，连续多个词头共用其后缩进的正文，第一个词头保存正文，其余词头生成 @@@LINK
+//	3、词头中 {...} 的内容不参与索引直接去除，(...) 可选部分同时生成去除与保留两种词头
+//	4、正文逐行转换，[b]、[i]、[m1] 等标签转换为 HTML，[ref] 转换为 entry:// 链接，[s] 转换为 sound:// 链接或图片
+func importDSL(cfg string) error {
+	var err error
+	var text string
+	var words, lines []string
+	var out *sourceWriter
+	var opt = new(ImportOption)
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(".dsl"); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if text, err = readTextFile(opt.Input); nil != err {
+		return err
+	}
+	if out, err = createSource(opt.Output); nil != err {
+		return err
+	}
+
+	var flush = func() error {
+		var err error
+		var body = new(strings.Builder)
+
+		if 0 == len(words) || 0 == len(lines) {
+			return nil
+		}
+
+		for _, line := range lines {
+			body.WriteString(dslLine(line, words[0]))
+		}
+		if err = out.Add(words[0], body.String()); nil != err {
+			return err
+		}
+		for _, word := range words[1:] {
+			if word != words[0] {
+				if err = out.Add(word, "@@@LINK="+words[0]); nil != err {
+					return err
+				}
+			}
+		}
+
+		words, lines = words[:0], lines[:0]
+
+		return nil
+	}
+
+	for _, line := range strings.Split(dslCommentRegex.ReplaceAllString(text, ""), "\n") {
+		if line = strings.TrimRight(line, "\r"); "" == strings.TrimSpace(line) {
+			continue
+		}
+
+		switch line[0] {
+		case '#':
+			if strings.HasPrefix(line, "#NAME") {
+				fmt.Println("dict:", strings.Trim(line[5:], "\t \""))
+			}
+		case ' ', '\t':
+			lines = append(lines, strings.TrimSpace(line))
+		default:
+			if len(lines) > 0 {
+				err = flush()
+			}
+
+			words = append(words, dslWords(line)...)
+		}
+		if nil != err {
+			break
+		}
+	}
+	if nil == err {
+		err = flush()
+	}
+
+	if cerr := out.Close(); nil == err {
+		err = cerr
+	}
+	if nil == err {
+		fmt.Println("import dsl, entries:", out.num)
+	}
+
+	return err
+}
+
+// dslUnescape 还原 DSL 中反斜杠转义的字符
+func dslUnescape(text string) string {
+	var escape bool
+	var buf = new(strings.Builder)
+
+	for _, c := range text {
+		if !escape && '\\' == c {
+			escape = true
+
+			continue
+		}
+
+		escape = false
+		buf.WriteRune(c)
+	}
+
+	return buf.String()
+}
+
+// dslWords 解析 DSL 词头行，去除 {...} 不参与索引的部分，(...) 可选部分生成两种词头
+func dslWords(line string) []string {
+	var c rune
+	var escape bool
+	var depth, option int
+	var short, full = new(strings.Builder), new(strings.Builder)
+
+	for _, c = range strings.TrimSpace(line) {
+		switch {
+		case escape:
+			escape = false
+		case '\\' == c:
+			escape = true
+
+			continue
+		case '{' == c:
+			depth++
+
+			continue
+		case '}' == c:
+			depth--
+
+			continue
+		case '(' == c && 0 == depth:
+			option++
+
+			continue
+		case ')' == c && 0 == depth && option > 0:
+			option--
+
+			continue
+		}
+
+		if depth > 0 {
+			continue
+		}
+		if 0 == option {
+			short.WriteRune(c)
+		}
+
+		full.WriteRune(c)
+	}
+
+	var ret = []string{strings.Join(strings.Fields(full.String()), " ")}
+	if word := strings.Join(strings.Fields(short.String()), " "); "" != word && word != ret[0] {
+		ret = append(ret, word)
+	}
+
+	return ret
+}
+
+// dslLine 把一行 DSL 正文转换为 HTML，没有 [m] 缩进标签的行用 div 包裹，行内未关闭的 div 在行尾关闭
+func dslLine(line string, word string) string {
+	var c byte
+	var fields []string
+	var tag, name, value string
+	var pos, end, div int
+	var close bool
+	var buf = new(strings.Builder)
+
+	if !strings.HasPrefix(line, "[m") {
+		buf.WriteString("<div>")
+		div++
+	}
+
+	for pos = 0; pos < len(line); pos++ {
+		switch c = line[pos]; c {
+		case '\\':
+			if pos+1 < len(line) {
+				pos++
+				buf.WriteString(html.EscapeString(line[pos : pos+1]))
+			}
+		case '~':
+			buf.WriteString(html.EscapeString(word))
+		case '[':
+			if end = strings.IndexByte(line[pos:], ']'); -1 == end {
+				buf.WriteString("[")
+
+				continue
+			}
+
+			tag = line[pos+1 : pos+end]
+			pos += end
+			if close = strings.HasPrefix(tag, "/"); close {
+				tag = tag[1:]
+			}
+			if fields = strings.Fields(tag); 0 == len(fields) {
+				continue
+			}
+
+			name = fields[0]
+
+			switch {
+			case "ref" == name || "s" == name || "url" == name || "video" == name:
+				if close {
+					continue
+				}
+				if end = strings.Index(line[pos+1:], "[/"+name+"]"); -1 == end {
+					end = len(line) - pos - 1
+				}
+
+				value = dslUnescape(line[pos+1 : pos+1+end])
+				pos += end + len(name) + 3
+				buf.WriteString(dslLink(name, value))
+			case 'm' == name[0] && strings.Trim(name[1:], "0123456789") == "":
+				if close {
+					if div > 0 {
+						buf.WriteString("</div>")
+						div--
+					}
+				} else {
+					if "m" == name {
+						name = "m0"
+					}
+
+					buf.WriteString(`<div style="margin-left:` + name[1:] + `em">`)
+					div++
+				}
+			case "c" == name:
+				if close {
+					buf.WriteString("</span>")
+				} else if value = strings.TrimSpace(tag[1:]); "" == value {
+					buf.WriteString(`<span style="color:green">`)
+				} else {
+					buf.WriteString(`<span style="color:` + html.EscapeString(value) + `">`)
+				}
+			default:
+				if v, ok := dslTags[name]; ok && close {
+					buf.WriteString(v[1])
+				} else if ok {
+					buf.WriteString(v[0])
+				}
+			}
+		case '<':
+			if strings.HasPrefix(line[pos:], "<<") {
+				if end = strings.Index(line[pos:], ">>"); -1 != end {
+					buf.WriteString(dslLink("ref", dslUnescape(line[pos+2:pos+end])))
+					pos += end + 1
+
+					continue
+				}
+			}
+
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		case '&':
+			buf.WriteString("&amp;")
+		case '"':
+			buf.WriteString("&#34;")
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	for ; div > 0; div-- {
+		buf.WriteString("</div>")
+	}
+
+	return buf.String()
+}
+
+// dslLink 转换 [ref]、[url]、[s]、[video] 标签，[s] 中的图片用 img 显示
+func dslLink(name string, value string) string {
+	var text = html.EscapeString(value)
+
+	switch name {
+	case "ref":
+		return `<a href="entry://` + text + `">` + text + "</a>"
+	case "url":
+		return `<a href="` + text + `">` + text + "</a>"
+	}
+
+	if dslMediaExt[strings.ToLower(filepath.Ext(value))] {
+		return `<img src="` + text + `"/>`
+	}
+
+	return `<a href="sound://` + text + `">&#9654;</a>`
+}
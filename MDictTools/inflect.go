@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// irregularTable 内置的英语不规则词形表
+//
+//go:embed irregular.txt
+var irregularTable string
+
+// InflectOption 英语屈折词形链接生成选项
+type InflectOption struct {
+	LinksOnly  bool                `label:"只输出生成的链接"`
+	Input      string              `label:"词典源文件"`
+	Output     string              `label:"输出的词典源文件"`
+	Report     string              `label:"冲突报告文件"`
+	Exceptions string              `label:"补充的不规则词形表"`
+	Lexicon    string              `label:"词性表"`
+	Forms      []string            `label:"按规则生成的词形"`
+	forms      map[string]bool     `label:"按规则生成的词形"`
+	lemmas     []string            `label:"不规则词形表中的原形"`
+	irregular  map[string][]string `label:"不规则词形"`
+	pos        map[string][]string `label:"词性表中单词的词性"`
+}
+
+// InflectConflict 同一词形可以由多个原形生成时的冲突
+type InflectConflict struct {
+	Form     string `label:"词形"`
+	Lemma    string `label:"跳过的原形"`
+	Existing string `label:"链接指向的原形"`
+}
+
+// InflectReport 英语屈折词形链接生成报告
+type InflectReport struct {
+	Lemmas    int                `label:"处理的原形数"`
+	Generated int                `label:"生成的链接数"`
+	Existing  int                `label:"已是词头而跳过的词形数"`
+	Conflicts []*InflectConflict `label:"冲突列表"`
+}
+
+// Init 检查屈折词形选项，加载不规则词形表
+func (o *InflectOption) Init() error {
+	var err error
+	var pos int
+	var data []byte
+	var msg = make([]string, 0, 2)
+
+	if "" == o.Input {
+		return errors.New("输入文件属性 Input 不能为空")
+	}
+	if _, err = os.Stat(o.Input); nil != err {
+		return errors.New("输入文件 " + o.Input + " 不存在")
+	}
+
+	pos = len(o.Input) - len(filepath.Ext(o.Input))
+	if "" == o.Output {
+		o.Output = o.Input[:pos] + ".inflect" + o.Input[pos:]
+	} else if o.Output == o.Input {
+		msg = append(msg, "输入文件和输出文件不能相同")
+	}
+	if "" == o.Report {
+		o.Report = o.Input[:pos] + ".inflect.json"
+	}
+
+	if 0 == len(o.Forms) {
+		o.Forms = []string{"noun"}
+	}
+	o.forms = make(map[string]bool, len(o.Forms))
+	for _, v := range o.Forms {
+		if "noun" != v && "verb" != v && "adj" != v {
+			msg = append(msg, "词形 Forms 只能是 noun、verb、adj")
+
+			break
+		}
+
+		o.forms[v] = true
+	}
+
+	o.irregular = make(map[string][]string, 200)
+	o.loadIrregular(irregularTable)
+	if "" != o.Exceptions {
+		if data, err = os.ReadFile(o.Exceptions); nil == err {
+			o.loadIrregular(string(data))
+		} else {
+			msg = append(msg, "不规则词形表 "+o.Exceptions+" 读取失败")
+		}
+	}
+	if "" != o.Lexicon {
+		if data, err = os.ReadFile(o.Lexicon); nil == err {
+			msg = append(msg, o.loadLexicon(string(data))...)
+		} else {
+			msg = append(msg, "词性表 "+o.Lexicon+" 读取失败")
+		}
+	}
+
+	if len(msg) > 0 {
+		err = errors.New(strings.Join(msg, "\n"))
+	}
+
+	return err
+}
+
+// loadIrregular 加载不规则词形表，每行第一个词为原形，其后为全部屈折形式
+func (o *InflectOption) loadIrregular(table string) {
+	for _, line := range strings.Split(table, "\n") {
+		if line = strings.TrimSpace(line); "" == line || '#' == line[0] {
+			continue
+		}
+
+		var fields = strings.Fields(line)
+		if _, ok := o.irregular[fields[0]]; !ok {
+			o.lemmas = append(o.lemmas, fields[0])
+		}
+
+		o.irregular[fields[0]] = fields[1:]
+	}
+}
+
+// loadLexicon 加载词性表，每行第一个词为单词，其后为 noun、verb、adj 中的一个或多个词性
+func (o *InflectOption) loadLexicon(table string) []string {
+	var msg []string
+
+	o.pos = make(map[string][]string, 10000)
+	for num, line := range strings.Split(table, "\n") {
+		if line = strings.TrimSpace(line); "" == line || '#' == line[0] {
+			continue
+		}
+
+		var fields = strings.Fields(line)
+		for _, v := range fields[1:] {
+			if "noun" != v && "verb" != v && "adj" != v {
+				return append(msg, "词性表 "+o.Lexicon+" 第 "+strconv.Itoa(num+1)+" 行的词性 "+v+" 只能是 noun、verb、adj")
+			}
+		}
+
+		o.pos[fields[0]] = append(o.pos[fields[0]], fields[1:]...)
+	}
+
+	return msg
+}
+
+// lemmaForms 返回原形按规则生成的词形，设置了词性表时动词与形容词词形只为表中标注了对应词性的单词生成
+func (o *InflectOption) lemmaForms(lemma string) map[string]bool {
+	var ret map[string]bool
+
+	if nil == o.pos {
+		return o.forms
+	}
+
+	ret = map[string]bool{"noun": o.forms["noun"]}
+	for _, v := range o.pos[lemma] {
+		ret[v] = ret[v] || o.forms[v]
+	}
+
+	return ret
+}
+
+// inflect 返回原形的屈折词形，不规则词形表中有的原形只使用表中的词形，其它原形只处理小写英文单词
+func (o *InflectOption) inflect(lemma string) []string {
+	var forms map[string]bool
+	var ret = make([]string, 0, 8)
+
+	if list, ok := o.irregular[lemma]; ok {
+		return list
+	}
+	if !isEnglishWord(lemma) {
+		return ret
+	}
+
+	forms = o.lemmaForms(lemma)
+	if forms["noun"] || forms["verb"] {
+		ret = append(ret, pluralForm(lemma))
+	}
+	if forms["verb"] {
+		ret = append(ret, suffixForm(lemma, "ing"), suffixForm(lemma, "ed"))
+	}
+	if forms["adj"] {
+		ret = append(ret, suffixForm(lemma, "er"), suffixForm(lemma, "est"))
+	}
+
+	return ret
+}
+
+// isEnglishWord 是否为两个字母以上的小写英文单词，允许中间有连字符
+func isEnglishWord(word string) bool {
+	if len(word) < 2 || '-' == word[0] || '-' == word[len(word)-1] {
+		return false
+	}
+
+	for _, c := range word {
+		if (c < 'a' || c > 'z') && '-' != c {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isVowel 是否为元音字母
+func isVowel(c byte) bool {
+	return 'a' == c || 'e' == c || 'i' == c || 'o' == c || 'u' == c
+}
+
+// vowelAt 单词中的字母是否为元音，qu 中的 u 与 q 一起作为辅音
+func vowelAt(word string, k int) bool {
+	return isVowel(word[k]) && !('u' == word[k] && k > 0 && 'q' == word[k-1])
+}
+
+// doubleFinal 重读闭音节结尾的单音节词加后缀时双写末尾的辅音字母，如 stop、big、quit
+func doubleFinal(word string) bool {
+	var n = len(word)
+	var groups int
+
+	if n < 3 || isVowel(word[n-1]) || strings.IndexByte("wxy", word[n-1]) >= 0 || !vowelAt(word, n-2) || vowelAt(word, n-3) {
+		return false
+	}
+
+	for k := 0; k < n; k++ {
+		if vowelAt(word, k) && (0 == k || !vowelAt(word, k-1)) {
+			groups++
+		}
+	}
+
+	return 1 == groups
+}
+
+// pluralForm 名词复数与动词第三人称单数：s、x、z、ch、sh 结尾加 es，重读闭音节的单音节词 z 结尾双写 z，辅音字母加 y 结尾改 y 为 ies，其它加 s
+//
+// 辅音字母加 o 结尾的单词有的加 es 有的加 s，按规则加 s，加 es 的单词如 potato、hero 在不规则词形表中
+func pluralForm(word string) string {
+	var n = len(word)
+
+	switch {
+	case 'z' == word[n-1] && doubleFinal(word):
+		return word + "zes"
+	case strings.HasSuffix(word, "s") || strings.HasSuffix(word, "x") || strings.HasSuffix(word, "z") ||
+		strings.HasSuffix(word, "ch") || strings.HasSuffix(word, "sh"):
+		return word + "es"
+	case 'y' == word[n-1] && !isVowel(word[n-2]):
+		return word[:n-1] + "ies"
+	}
+
+	return word + "s"
+}
+
+// suffixForm 加 ing、ed、er、est 后缀，处理不发音的 e、辅音字母加 y、ie 结尾与双写辅音字母
+func suffixForm(word string, suffix string) string {
+	var n = len(word)
+
+	switch {
+	case "ing" == suffix && strings.HasSuffix(word, "ie"):
+		return word[:n-2] + "ying"
+	case "ing" == suffix && strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "ee") &&
+		!strings.HasSuffix(word, "ye") && !strings.HasSuffix(word, "oe"):
+		return word[:n-1] + suffix
+	case "ing" != suffix && 'e' == word[n-1]:
+		return word + suffix[1:]
+	case "ing" != suffix && 'y' == word[n-1] && !isVowel(word[n-2]):
+		return word[:n-1] + "i" + suffix
+	case doubleFinal(word):
+		return word + word[n-1:] + suffix
+	}
+
+	return word + suffix
+}
+
+// Save 保存屈折词形冲突报告
+func (r *InflectReport) Save(file string) error {
+	var data, err = json.MarshalIndent(r, "", "    ")
+	if nil != err {
+		return err
+	}
+
+	return FilePutContents(file, data, false)
+}
+
+// generateInflections 为英语原形词头生成屈折词形的链接
+//
+// 实现思路：
+//
+//	1、第一遍读取全部词头与链接，已是词头或链接的词形不再生成
+//	2、第二遍按源文件顺序复制词条，不规则词形表中的原形使用表中的词形，其它小写英文单词按规则生成复数、ing、ed 等词形
+//	3、同一词形由多个原形生成时，不规则词形表中的原形优先，其次按源文件顺序保留第一个，其余记入冲突报告
+//	4、生成的 @@@LINK 词条追加到输出文件末尾
+func generateInflections(cfg string) error {
+	var num int
+	var err error
+	var chunk []byte
+	var element *Entry
+	var fp *os.File
+	var buf *bufio.Writer
+	var reader *EntryReader
+	var words map[string]bool
+	var links map[string]string
+	var opt = new(InflectOption)
+	var done = make(map[string]bool, 100000)
+	var claimed = make(map[string]string, 1000)
+	var generated = make(map[string]string, 100000)
+	var list = make([]string, 0, 100000)
+	var report = &InflectReport{Conflicts: make([]*InflectConflict, 0, 100)}
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if words, links, err = scanWordLinks(opt.Input, func(chunk []byte, offset int64) *Entry {
+		return parseBody(chunk, 0, len(chunk))
+	}); nil != err {
+		return err
+	}
+
+	// 不规则词形优先，词典中有的不规则原形按表中的顺序先占用词形
+	for _, lemma := range opt.lemmas {
+		if !words[lemma] {
+			continue
+		}
+
+		for _, form := range opt.irregular[lemma] {
+			if _, ok := claimed[form]; !ok {
+				claimed[form] = lemma
+			}
+		}
+	}
+
+	if reader, err = OpenEntryReader(opt.Input); nil != err {
+		return err
+	}
+	defer reader.Close()
+
+	if fp, err = os.OpenFile(opt.Output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	buf = bufio.NewWriterSize(fp, 1<<20)
+	for {
+		if chunk, _, err = reader.Next(); nil != err {
+			break
+		}
+		if !opt.LinksOnly {
+			if num > 0 {
+				buf.WriteString("\r\n</>\r\n")
+			}
+
+			num++
+			buf.Write(chunk)
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element || "" != element.action || done[element.word] {
+			continue
+		}
+
+		done[element.word] = true
+		report.Lemmas++
+		for _, form := range opt.inflect(element.word) {
+			if form == element.word {
+				continue
+			}
+			if words[form] || "" != links[form] {
+				report.Existing++
+
+				continue
+			}
+			if lemma, ok := claimed[form]; ok && lemma != element.word {
+				report.Conflicts = append(report.Conflicts, &InflectConflict{Form: form, Lemma: element.word, Existing: lemma})
+
+				continue
+			}
+			if lemma, ok := generated[form]; ok {
+				if lemma != element.word {
+					report.Conflicts = append(report.Conflicts, &InflectConflict{Form: form, Lemma: element.word, Existing: lemma})
+				}
+
+				continue
+			}
+
+			generated[form] = element.word
+			list = append(list, form)
+		}
+	}
+	if io.EOF != err {
+		return err
+	}
+
+	for _, form := range list {
+		if num > 0 {
+			buf.WriteString("\r\n</>\r\n")
+		}
+
+		num++
+		buf.WriteString(form + "\r\n@@@LINK=" + generated[form])
+	}
+	if err = buf.Flush(); nil != err {
+		return err
+	}
+
+	report.Generated = len(list)
+	fmt.Println("lemmas:", report.Lemmas, "links:", report.Generated, "existing:", report.Existing, "conflicts:", len(report.Conflicts))
+
+	return report.Save(opt.Report)
+}
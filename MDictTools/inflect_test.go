@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInflectForms(t *testing.T) {
+	var cases = []struct {
+		word   string
+		suffix string
+		want   string
+	}{
+		{"cat", "s", "cats"},
+		{"box", "s", "boxes"},
+		{"city", "s", "cities"},
+		{"day", "s", "days"},
+		{"quiz", "s", "quizzes"},
+		{"buzz", "s", "buzzes"},
+		{"waltz", "s", "waltzes"},
+		{"photo", "s", "photos"},
+		{"stop", "ing", "stopping"},
+		{"quit", "ing", "quitting"},
+		{"squat", "ed", "squatted"},
+		{"quiet", "er", "quieter"},
+		{"make", "ing", "making"},
+		{"see", "ing", "seeing"},
+		{"die", "ing", "dying"},
+		{"try", "ed", "tried"},
+		{"play", "ed", "played"},
+		{"big", "est", "biggest"},
+		{"fix", "ed", "fixed"},
+		{"open", "ed", "opened"},
+	}
+
+	for _, v := range cases {
+		var got string
+		if "s" == v.suffix {
+			got = pluralForm(v.word)
+		} else {
+			got = suffixForm(v.word, v.suffix)
+		}
+		if got != v.want {
+			t.Errorf("%s 加 %s 为 %q，应为 %q", v.word, v.suffix, got, v.want)
+		}
+	}
+}
+
+func TestInflectLexicon(t *testing.T) {
+	var opt = &InflectOption{forms: map[string]bool{"noun": true, "verb": true, "adj": true}, irregular: make(map[string][]string, 200)}
+	var cases = map[string]string{
+		"walk":   "walks walking walked",
+		"beauty": "beauties",
+		"happy":  "happies happier happiest",
+		"go":     "goes going went gone",
+		"potato": "potatoes",
+		"hero":   "heroes",
+	}
+
+	opt.loadIrregular(irregularTable)
+	if msg := opt.loadLexicon("# 词性表\nwalk noun verb\nbeauty noun\nhappy adj\n"); len(msg) > 0 {
+		t.Fatal(msg)
+	}
+	if msg := opt.loadLexicon("walk noun v\n"); 1 != len(msg) {
+		t.Errorf("不支持的词性应报错，%v", msg)
+	}
+
+	opt.loadLexicon("walk noun verb\nbeauty noun\nhappy adj\n")
+	for word, want := range cases {
+		if got := strings.Join(opt.inflect(word), " "); got != want {
+			t.Errorf("%s 的词形为 %q，应为 %q", word, got, want)
+		}
+	}
+}
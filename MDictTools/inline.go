@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// inlineStyleMark 生成的样式在 CSS 文件中的起始标记，重新生成时替换标记之后的内容
+const inlineStyleMark = "/* MDictTools inline styles */"
+
+// classAttrRegex 源文件中的 class 属性
+var classAttrRegex = regexp.MustCompile(`(?i)\sclass\s*=\s*["']?([^"'>]*)`)
+
+// cssClassRegex CSS 文件中的类选择器
+var cssClassRegex = regexp.MustCompile(`\.(-?[A-Za-z_][\w-]*)`)
+
+// InlineStyleOption 内联样式转换选项
+type InlineStyleOption struct {
+	Important bool   `label:"生成的样式加上 !important"`
+	MinCount  int    `label:"转换为类名的最少出现次数"`
+	Input     string `label:"词典源文件"`
+	Output    string `label:"输出的词典源文件"`
+	CSS       string `label:"追加样式的 CSS 文件"`
+	Report    string `label:"转换报告文件"`
+	Prefix    string `label:"生成的类名前缀"`
+}
+
+// InlineStyleClass 内联样式生成的类
+type InlineStyleClass struct {
+	Class string `label:"类名"`
+	Style string `label:"样式内容"`
+	Count int    `label:"出现次数"`
+	first int    `label:"首次出现的顺序"`
+}
+
+// InlineStyleReport 内联样式转换报告
+type InlineStyleReport struct {
+	Styles       int                 `label:"转换的样式数"`
+	Tags         int                 `label:"转换的标签数"`
+	Kept         int                 `label:"出现次数不足而保留的样式数"`
+	SourceBefore int64               `label:"转换前的源文件字节数"`
+	SourceAfter  int64               `label:"转换后的源文件字节数"`
+	CSSAdded     int64               `label:"追加的 CSS 字节数"`
+	Saved        int64               `label:"节省的字节数"`
+	Classes      []*InlineStyleClass `label:"生成的类列表"`
+}
+
+// Init 检查内联样式转换选项
+func (o *InlineStyleOption) Init() error {
+	var err error
+	var pos int
+	var msg = make([]string, 0, 2)
+
+	if "" == o.Input {
+		return errors.New("输入文件属性 Input 不能为空")
+	}
+	if _, err = os.Stat(o.Input); nil != err {
+		return errors.New("输入文件 " + o.Input + " 不存在")
+	}
+
+	pos = len(o.Input) - len(filepath.Ext(o.Input))
+	if "" == o.Output {
+		o.Output = o.Input[:pos] + ".inline" + o.Input[pos:]
+	} else if o.Output == o.Input {
+		msg = append(msg, "输入文件和输出文件不能相同")
+	}
+	if "" == o.Report {
+		o.Report = o.Input[:pos] + ".inline.json"
+	}
+	if "" == o.CSS {
+		msg = append(msg, "CSS 文件属性 CSS 不能为空")
+	}
+	if "" == o.Prefix {
+		o.Prefix = "s"
+	} else if !isClassName(o.Prefix) {
+		msg = append(msg, "类名前缀 Prefix 只能包含字母、数字、- 与 _，并以字母开头")
+	}
+	if o.MinCount < 1 {
+		o.MinCount = 1
+	}
+
+	if len(msg) > 0 {
+		err = errors.New(strings.Join(msg, "\n"))
+	}
+
+	return err
+}
+
+// isClassName 是否为以字母开头，只包含字母、数字、- 与 _ 的类名
+func isClassName(name string) bool {
+	for k, c := range name {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (0 == k || ((c < '0' || c > '9') && '-' != c && '_' != c)) {
+			return false
+		}
+	}
+
+	return "" != name
+}
+
+// splitDeclarations 按引号与括号外的分号拆分样式声明
+func splitDeclarations(style string) []string {
+	var depth, pos int
+	var quote rune
+	var ret = make([]string, 0, 4)
+
+	for k, c := range style {
+		switch {
+		case 0 != quote:
+			if c == quote {
+				quote = 0
+			}
+		case '"' == c || '\'' == c:
+			quote = c
+		case '(' == c:
+			depth++
+		case ')' == c && depth > 0:
+			depth--
+		case ';' == c && 0 == depth:
+			ret = append(ret, style[pos:k])
+			pos = k + 1
+		}
+	}
+
+	return append(ret, style[pos:])
+}
+
+// normalizeStyle 规范内联样式：属性名转为小写，合并多余的空白，去掉空的声明，内容相同的样式得到相同的结果
+func normalizeStyle(style string) string {
+	var pos int
+	var name, value string
+	var ret = make([]string, 0, 4)
+
+	for _, decl := range splitDeclarations(html.UnescapeString(style)) {
+		if pos = strings.IndexByte(decl, ':'); pos < 1 {
+			continue
+		}
+
+		name = strings.ToLower(strings.TrimSpace(decl[:pos]))
+		value = strings.Join(strings.Fields(decl[pos+1:]), " ")
+		if "" != name && "" != value {
+			ret = append(ret, name+":"+value)
+		}
+	}
+
+	return strings.Join(ret, ";")
+}
+
+// styleTags 返回词条中带有 style 属性的元素标签，词条不含 style 时不解析 DOM
+func styleTags(element *Entry, chunk []byte) (*Dom, []*Tag) {
+	var dom *Dom
+	var tags []*Tag
+
+	if !strings.Contains(strings.ToLower(string(chunk)), "style") {
+		return nil, nil
+	}
+
+	dom = parseBodyItem(element, string(chunk))
+	for _, tag := range dom.root {
+		if tag.state && tag.hasAttr && tag.isElement() && nil != tag.Get("style") {
+			tags = append(tags, tag)
+		}
+	}
+
+	return dom, tags
+}
+
+// classRule 生成类的 CSS 规则，每条声明一行
+func (o *InlineStyleOption) classRule(class *InlineStyleClass, separator string) string {
+	var buf = new(strings.Builder)
+
+	buf.WriteString("." + class.Class + " {" + separator)
+	for _, decl := range strings.Split(class.Style, ";") {
+		if o.Important && !strings.HasSuffix(decl, "!important") {
+			decl += " !important"
+		}
+
+		buf.WriteString("    " + strings.Replace(decl, ":", ": ", 1) + ";" + separator)
+	}
+	buf.WriteString("}")
+
+	return buf.String()
+}
+
+// usedClasses 返回 CSS 文件中生成标记之前的类名，生成的类名需要避开
+func (o *InlineStyleOption) usedClasses() (map[string]bool, error) {
+	var data, err = os.ReadFile(o.CSS)
+	var used = make(map[string]bool, 1000)
+
+	if nil != err {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+
+		return used, err
+	}
+	if pos := strings.Index(string(data), inlineStyleMark); pos >= 0 {
+		data = data[:pos]
+	}
+	for _, v := range cssClassRegex.FindAllSubmatch(data, -1) {
+		used[string(v[1])] = true
+	}
+
+	return used, nil
+}
+
+// appendCSS 把生成的规则追加到 CSS 文件，替换上次生成的内容，返回追加的字节数
+func (o *InlineStyleOption) appendCSS(classes []*InlineStyleClass) (int64, error) {
+	var err error
+	var data []byte
+	var content string
+	var separator = "\n"
+	var rules = make([]string, 0, len(classes)+1)
+
+	if data, err = os.ReadFile(o.CSS); nil != err && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	content = string(data)
+	if pos := strings.Index(content, inlineStyleMark); pos >= 0 {
+		content = content[:pos]
+	}
+	if strings.Contains(content, "\r\n") {
+		separator = "\r\n"
+	}
+	if content = strings.TrimRight(content, "\r\n\t "); "" != content {
+		content += separator + separator
+	}
+
+	rules = append(rules, inlineStyleMark)
+	for _, class := range classes {
+		rules = append(rules, o.classRule(class, separator))
+	}
+
+	data = []byte(strings.Join(rules, separator+separator) + separator)
+	if err = FilePutContents(o.CSS, append([]byte(content), data...), false); nil != err {
+		return 0, err
+	}
+
+	return int64(len(data)), nil
+}
+
+// Save 保存内联样式转换报告
+func (r *InlineStyleReport) Save(file string) error {
+	var data, err = json.MarshalIndent(r, "", "    ")
+	if nil != err {
+		return err
+	}
+
+	return FilePutContents(file, data, false)
+}
+
+// convertInlineStyles 把元素标签的内联样式转换为生成的类名，生成的样式追加到 CSS 文件
+//
+// 实现思路：
+//
+//	1、第一遍读取全部词条，统计每种内联样式的出现次数与源文件中已经使用的类名
+//	2、样式按出现次数从多到少用前缀加 36 进制序号命名，出现次数多的类名短，跳过源文件中已有的类名
+//	3、第二遍复制词条，去掉达到最少出现次数的样式的 style 属性并添加对应的类名，不含内联样式的词条原样复制
+//	4、生成的规则追加到 CSS 文件末尾的标记之后，重新生成时替换上次生成的内容，再交给 css 入口整理
+//	5、转换前后源文件的大小与追加的 CSS 大小保存为报告
+func convertInlineStyles(cfg string) error {
+	var num, seq int
+	var err error
+	var chunk []byte
+	var name, body, style string
+	var dom *Dom
+	var tag *Tag
+	var tags []*Tag
+	var element *Entry
+	var fp *os.File
+	var buf *bufio.Writer
+	var reader *EntryReader
+	var used map[string]bool
+	var opt = &InlineStyleOption{Important: true}
+	var styles = make(map[string]*InlineStyleClass, 1000)
+	var classes = make([]*InlineStyleClass, 0, 1000)
+	var report = new(InlineStyleReport)
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if used, err = opt.usedClasses(); nil != err {
+		return err
+	}
+	if reader, err = OpenEntryReader(opt.Input); nil != err {
+		return err
+	}
+	for {
+		if chunk, _, err = reader.Next(); nil != err {
+			break
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element || "" != element.action {
+			continue
+		}
+
+		for _, v := range classAttrRegex.FindAllSubmatch(chunk, -1) {
+			for _, name := range strings.Fields(string(v[1])) {
+				used[name] = true
+			}
+		}
+		_, tags = styleTags(element, chunk)
+		for _, tag = range tags {
+			if style = normalizeStyle(tag.Get("style").value); "" == style {
+				continue
+			}
+			if class, ok := styles[style]; ok {
+				class.Count++
+			} else {
+				styles[style] = &InlineStyleClass{Style: style, Count: 1, first: len(styles)}
+			}
+		}
+	}
+	reader.Close()
+	if io.EOF != err {
+		return err
+	}
+
+	for _, class := range styles {
+		if class.Count >= opt.MinCount {
+			classes = append(classes, class)
+		} else {
+			report.Kept++
+		}
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if classes[i].Count != classes[j].Count {
+			return classes[i].Count > classes[j].Count
+		}
+
+		return classes[i].first < classes[j].first
+	})
+	for _, class := range classes {
+		for name = opt.Prefix + strconv.FormatInt(int64(seq), 36); used[name]; name = opt.Prefix + strconv.FormatInt(int64(seq), 36) {
+			seq++
+		}
+
+		seq++
+		class.Class = name
+	}
+
+	if reader, err = OpenEntryReader(opt.Input); nil != err {
+		return err
+	}
+	defer reader.Close()
+
+	if fp, err = os.OpenFile(opt.Output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	buf = bufio.NewWriterSize(fp, 1<<20)
+	for {
+		if chunk, _, err = reader.Next(); nil != err {
+			break
+		}
+		if num > 0 {
+			buf.WriteString("\r\n</>\r\n")
+		}
+
+		num++
+		body = string(chunk)
+		if element = parseBody(chunk, 0, len(chunk)); nil != element && "" == element.action {
+			if dom, tags = styleTags(element, chunk); len(tags) > 0 {
+				for _, tag = range tags {
+					style = normalizeStyle(tag.Get("style").value)
+					if class := styles[style]; nil != class && "" != class.Class {
+						tag.StripAttr([]string{"style"})
+						tag.AddClass([]string{class.Class})
+						report.Tags++
+					} else if "" == style {
+						tag.StripAttr([]string{"style"})
+					}
+				}
+
+				body = dom.ToString(false)
+			}
+		}
+
+		report.SourceBefore += int64(len(chunk))
+		report.SourceAfter += int64(len(body))
+		buf.WriteString(body)
+	}
+	if io.EOF != err {
+		return err
+	}
+	if err = buf.Flush(); nil != err {
+		return err
+	}
+
+	if report.CSSAdded, err = opt.appendCSS(classes); nil != err {
+		return err
+	}
+
+	report.Styles = len(classes)
+	report.Classes = classes
+	report.Saved = report.SourceBefore - report.SourceAfter - report.CSSAdded
+	fmt.Println("styles:", report.Styles, "tags:", report.Tags, "kept:", report.Kept, "saved bytes:", report.Saved)
+
+	return report.Save(opt.Report)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// LinkOption 词典链接检查选项
+type LinkOption struct {
+	Collapse  bool   `label:"把多级链接改为直接指向词条"`
+	DropLinks string `label:"去除的无效链接"`
+	Input     string `label:"词典源文件"`
+	Output    string `label:"输出的词典源文件"`
+	Report    string `label:"链接检查报告文件"`
+}
+
+// LinkItem 无效的链接
+type LinkItem struct {
+	Word   string `label:"链接词头"`
+	Target string `label:"链接目标"`
+	End    string `label:"链接最终指向的词头"`
+	Reason string `label:"无效原因"`
+}
+
+// LinkReport 链接检查报告
+type LinkReport struct {
+	Links     int               `label:"链接总数"`
+	Dangling  []*LinkItem       `label:"目标不存在或指向循环的链接"`
+	SelfLinks []string          `label:"指向自身的链接"`
+	Cycles    [][]string        `label:"循环链接"`
+	Chains    [][]string        `label:"多级链接"`
+	dropped   map[string]bool   `label:"无效的链接词头"`
+	missing   map[string]bool   `label:"最终指向不存在词头的链接词头"`
+	final     map[string]string `label:"链接最终指向的词条"`
+}
+
+// Init 检查链接检查选项
+func (o *LinkOption) Init() error {
+	var err error
+	var msg = make([]string, 0, 2)
+
+	if "" == o.Input {
+		msg = append(msg, "输入文件属性 Input 不能为空")
+	} else if _, err = os.Stat(o.Input); nil != err {
+		msg = append(msg, "输入文件 "+o.Input+" 不存在")
+	} else if "" == o.Report {
+		if pos := strings.LastIndex(o.Input, "."); pos > 0 {
+			o.Report = o.Input[:pos] + ".links.json"
+		} else {
+			o.Report = o.Input + ".links.json"
+		}
+	}
+	if "" != o.Output && o.Input == o.Output {
+		msg = append(msg, "输入文件和输出文件不能相同")
+	}
+	if o.Collapse && "" == o.Output {
+		msg = append(msg, "合并多级链接 Collapse 需要设置输出文件 Output")
+	}
+	if "" == o.DropLinks {
+		o.DropLinks = "missing"
+	} else if err = checkDropLinks(o.DropLinks); nil != err {
+		msg = append(msg, err.Error())
+	}
+
+	if len(msg) > 0 {
+		err = errors.New(strings.Join(msg, "\n"))
+	}
+
+	return err
+}
+
+// checkDropLinks 检查无效链接的去除方式，missing 只去除最终指向不存在词头的链接，all 同时去除指向自身与循环的链接
+func checkDropLinks(drop string) error {
+	switch drop {
+	case "", "missing", "all":
+		return nil
+	}
+
+	return errors.New("无效链接去除方式 DropLinks 只能是 missing、all")
+}
+
+// drop 判断按去除方式是否去除链接词头
+func (r *LinkReport) drop(word string, drop string) bool {
+	switch drop {
+	case "missing":
+		return r.missing[word]
+	case "all":
+		return r.dropped[word]
+	}
+
+	return false
+}
+
+// analyzeLinks 沿链接查找每个链接最终指向的词条，找出目标不存在、指向自身、循环与多级的链接
+//
+// 实现思路：
+//
+//	1、从链接词头出发依次查找链接目标，目标是正常词条时链接有效，经过两次以上跳转的记为多级链接
+//	2、目标是自身时记为自身链接，回到出发的词头时记为循环，回到途中的词头时记为指向循环的无效链接
+//	3、目标既不是词条也不是链接时记为目标不存在的无效链接
+func analyzeLinks(words map[string]bool, links map[string]string) *LinkReport {
+	var ok bool
+	var idx int
+	var cur string
+	var path []string
+	var seen map[string]int
+	var keys = make([]string, 0, len(links))
+	var cycles = make(map[string]bool, 10)
+	var report = &LinkReport{
+		Links:     len(links),
+		Dangling:  make([]*LinkItem, 0, 10),
+		SelfLinks: make([]string, 0, 10),
+		Cycles:    make([][]string, 0, 10),
+		Chains:    make([][]string, 0, 10),
+		dropped:   make(map[string]bool, 10),
+		missing:   make(map[string]bool, 10),
+		final:     make(map[string]string, len(links)),
+	}
+
+	for k := range links {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, word := range keys {
+		path = []string{word}
+		seen = map[string]int{word: 0}
+		cur = links[word]
+		for {
+			if words[cur] {
+				report.final[word] = cur
+				if len(path) > 1 {
+					report.Chains = append(report.Chains, append(path, cur))
+				}
+
+				break
+			}
+			if cur == word && 1 == len(path) {
+				report.SelfLinks = append(report.SelfLinks, word)
+				report.dropped[word] = true
+
+				break
+			}
+			if idx, ok = seen[cur]; ok {
+				report.dropped[word] = true
+				if 0 != idx {
+					report.Dangling = append(report.Dangling, &LinkItem{Word: word, Target: links[word], End: cur, Reason: "cycle"})
+				} else if key := cycleKey(path); !cycles[key] {
+					cycles[key] = true
+					report.Cycles = append(report.Cycles, append(path, cur))
+				}
+
+				break
+			}
+			if _, ok = links[cur]; !ok {
+				report.Dangling = append(report.Dangling, &LinkItem{Word: word, Target: links[word], End: cur, Reason: "missing"})
+				report.dropped[word] = true
+				report.missing[word] = true
+
+				break
+			}
+
+			seen[cur] = len(path)
+			path = append(path, cur)
+			cur = links[cur]
+		}
+	}
+
+	return report
+}
+
+// cycleKey 返回循环链接的唯一标识，从最小的词头开始旋转，同一个循环只报告一次
+func cycleKey(path []string) string {
+	var min int
+
+	for k, v := range path {
+		if v < path[min] {
+			min = k
+		}
+	}
+
+	return strings.Join(append(append([]string{}, path[min:]...), path[:min]...), "\n")
+}
+
+// Save 保存链接检查报告
+func (r *LinkReport) Save(file string) error {
+	var data, err = json.MarshalIndent(r, "", "    ")
+	if nil != err {
+		return err
+	}
+
+	return FilePutContents(file, data, false)
+}
+
+// Print 输出链接检查概况
+func (r *LinkReport) Print() {
+	fmt.Println("links:", r.Links, "dangling:", len(r.Dangling), "self links:", len(r.SelfLinks), "cycles:", len(r.Cycles), "chains:", len(r.Chains))
+}
+
+// scanWordLinks 读取词典源文件中的词头与链接
+func scanWordLinks(file string, prepare func([]byte, int64) *Entry) (map[string]bool, map[string]string, error) {
+	var err error
+	var offset int64
+	var chunk []byte
+	var element *Entry
+	var reader *EntryReader
+	var words = make(map[string]bool, 100000)
+	var links = make(map[string]string, 100)
+
+	if reader, err = OpenEntryReader(file); nil != err {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	for {
+		if chunk, offset, err = reader.Next(); nil != err {
+			break
+		}
+		if element = prepare(chunk, offset); nil == element {
+			continue
+		}
+
+		if "link" == strings.ToLower(element.action) {
+			links[element.word] = element.value
+		} else {
+			words[element.word] = true
+		}
+	}
+	if io.EOF != err {
+		return nil, nil, err
+	}
+
+	return words, links, nil
+}
+
+// checkLinks 检查词典链接，设置了输出文件时去除无效链接并按需合并多级链接
+func checkLinks(cfg string) error {
+	var num int
+	var err error
+	var fp *os.File
+	var chunk []byte
+	var element *Entry
+	var buf *bufio.Writer
+	var reader *EntryReader
+	var report *LinkReport
+	var words map[string]bool
+	var links map[string]string
+	var opt = new(LinkOption)
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if words, links, err = scanWordLinks(opt.Input, func(chunk []byte, offset int64) *Entry {
+		return parseBody(chunk, 0, len(chunk))
+	}); nil != err {
+		return err
+	}
+
+	report = analyzeLinks(words, links)
+	report.Print()
+	if err = report.Save(opt.Report); nil != err || "" == opt.Output {
+		return err
+	}
+
+	if reader, err = OpenEntryReader(opt.Input); nil != err {
+		return err
+	}
+	defer reader.Close()
+
+	if fp, err = os.OpenFile(opt.Output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	buf = bufio.NewWriterSize(fp, 1<<20)
+	for {
+		if chunk, _, err = reader.Next(); nil != err {
+			break
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element {
+			continue
+		}
+		if "link" == strings.ToLower(element.action) {
+			if report.drop(element.word, opt.DropLinks) {
+				continue
+			}
+			if opt.Collapse && "" != report.final[element.word] && report.final[element.word] != element.value {
+				chunk = []byte(element.word + "\r\n@@@LINK=" + report.final[element.word])
+			}
+		}
+
+		if num > 0 {
+			_, _ = buf.WriteString("\r\n</>\r\n")
+		}
+		if _, err = buf.Write(chunk); nil != err {
+			return err
+		}
+
+		num++
+	}
+	if io.EOF != err {
+		return err
+	}
+
+	return buf.Flush()
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// lintRules 检查规则与默认级别
+var lintRules = map[string]string{
+	"unclosed-tag":    "error",
+	"misnested-tag":   "error",
+	"unmatched-close": "warning",
+	"stray-lt":        "warning",
+	"empty-body":      "error",
+	"long-word":       "warning",
+	"disallowed-char": "warning",
+}
+
+// voidTags 没有结束标签的 HTML 标签
+var voidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// LintOption 词典源文件检查选项
+type LintOption struct {
+	MaxWordLength   int               `label:"词头最大字节数"`
+	Threshold       int               `label:"允许的问题数"`
+	FailOn          string            `label:"计入阈值的问题级别"`
+	Input           string            `label:"词典源文件"`
+	Report          string            `label:"检查报告文件"`
+	Format          string            `label:"报告格式"`
+	DisallowedChars string            `label:"不允许出现的字符"`
+	Rules           map[string]string `label:"规则级别"`
+}
+
+// LintIssue 检查发现的问题
+type LintIssue struct {
+	Index    int    `label:"词条序号"`
+	Word     string `label:"词头"`
+	Offset   int64  `label:"文件字节偏移"`
+	Severity string `label:"级别"`
+	Rule     string `label:"规则"`
+	Message  string `label:"问题描述"`
+}
+
+// LintReport 检查报告
+type LintReport struct {
+	Entries  int          `label:"词条数"`
+	Errors   int          `label:"错误数"`
+	Warnings int          `label:"警告数"`
+	Issues   []*LintIssue `label:"问题列表"`
+}
+
+// lintTag 标签栈中的开始标签
+type lintTag struct {
+	name string `label:"标签名"`
+	pos  int    `label:"词条内的字节位置"`
+}
+
+// Init 检查配置
+func (o *LintOption) Init() error {
+	var err error
+	var msg = make([]string, 0, 2)
+
+	if "" == o.Input {
+		msg = append(msg, "输入文件属性 Input 不能为空")
+	} else if _, err = os.Stat(o.Input); nil != err {
+		msg = append(msg, "输入文件 "+o.Input+" 不存在")
+	}
+	if "" == o.Format {
+		if strings.HasSuffix(strings.ToLower(o.Report), ".csv") {
+			o.Format = "csv"
+		} else {
+			o.Format = "json"
+		}
+	}
+	if "json" != o.Format && "csv" != o.Format {
+		msg = append(msg, "报告格式 Format 只能是 json、csv")
+	}
+	if "" == o.Report && "" != o.Input {
+		if pos := strings.LastIndex(o.Input, "."); pos > 0 {
+			o.Report = o.Input[:pos] + ".lint." + o.Format
+		} else {
+			o.Report = o.Input + ".lint." + o.Format
+		}
+	}
+	if o.MaxWordLength < 1 {
+		o.MaxWordLength = 1024
+	}
+	if "" == o.FailOn {
+		o.FailOn = "error"
+	}
+	if "error" != o.FailOn && "warning" != o.FailOn {
+		msg = append(msg, "计入阈值的问题级别 FailOn 只能是 error、warning")
+	}
+	for k, v := range o.Rules {
+		if _, ok := lintRules[k]; !ok {
+			msg = append(msg, "不支持的检查规则 "+k)
+		} else if "error" != v && "warning" != v && "off" != v {
+			msg = append(msg, "检查规则 "+k+" 的级别只能是 error、warning、off")
+		}
+	}
+
+	if len(msg) > 0 {
+		err = errors.New(strings.Join(msg, "\n"))
+	}
+
+	return err
+}
+
+// severity 返回规则的级别，关闭的规则返回空字符串
+func (o *LintOption) severity(rule string) string {
+	var level, ok = o.Rules[rule]
+	if !ok {
+		level = lintRules[rule]
+	}
+	if "off" == level {
+		return ""
+	}
+
+	return level
+}
+
+// add 记录问题，pos 为词条内的字节位置
+func (r *LintReport) add(opt *LintOption, element *Entry, index int, pos int, rule string, msg string) {
+	var level = opt.severity(rule)
+	if "" == level {
+		return
+	}
+
+	if "error" == level {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+
+	r.Issues = append(r.Issues, &LintIssue{
+		Index:    index,
+		Word:     element.word,
+		Offset:   element.offset + int64(pos),
+		Severity: level,
+		Rule:     rule,
+		Message:  msg,
+	})
+}
+
+// lintEntry 检查单个词条
+//
+// 实现思路：
+//
+//	1、检查词头长度与整个词条中不允许出现的字符
+//	2、链接词条不再检查正文，其他词条正文为空时记为问题
+//	3、用 tidy 的 parseBodyItem 把正文解析为标签，按整理时看到的标签检查，开始标签入栈，结束标签与栈顶比对，不匹配但在栈中时为交叉嵌套，不在栈中时为多余的结束标签
+//	4、标签之外的 < 记为多余的 <，未结束的注释记为未关闭，script 与 style 中的内容不检查
+//	5、扫描结束后栈中剩余的标签为未关闭的标签
+func (r *LintReport) lintEntry(opt *LintOption, element *Entry, index int, data string) {
+	var dom *Dom
+	var raw, name, body string
+	var end, pos, next, start int
+	var issues = len(r.Issues)
+	var stack = make([]*lintTag, 0, 20)
+
+	defer func() {
+		sort.SliceStable(r.Issues[issues:], func(i int, j int) bool {
+			return r.Issues[issues+i].Offset < r.Issues[issues+j].Offset
+		})
+	}()
+
+	if len(element.word) > opt.MaxWordLength {
+		r.add(opt, element, index, 0, "long-word", "词头长度 "+strconv.Itoa(len(element.word))+" 字节超过 "+strconv.Itoa(opt.MaxWordLength))
+	}
+	for k, v := range data {
+		if utf8.RuneError == v || (v < 0x20 && '\t' != v && '\r' != v && '\n' != v) || 0x7f == v || strings.ContainsRune(opt.DisallowedChars, v) {
+			r.add(opt, element, index, k, "disallowed-char", "不允许出现的字符 "+strconv.QuoteRune(v))
+		}
+	}
+
+	if "" != element.action {
+		return
+	}
+	if start = strings.IndexAny(data, "\r\n"); -1 == start {
+		start = len(data)
+	}
+	for ; start < len(data) && ('\r' == data[start] || '\n' == data[start]); start++ {
+	}
+	if body = strings.TrimSpace(data[start:]); "" == body {
+		r.add(opt, element, index, 0, "empty-body", "正文为空")
+
+		return
+	}
+
+	dom = parseBodyItem(element, data[start:])
+	for k, tag := range dom.root {
+		if pos, next = start+tag.pos, len(data); k+1 < len(dom.root) {
+			next = start + dom.root[k+1].pos
+		}
+
+		// end 为标签结束后的位置，之后到下一个标签之间为文本或解析时丢弃的内容
+		switch tag.category {
+		case "content", "raw":
+			end = pos
+		case "comment":
+			end = pos + len(tag.value)
+		default:
+			if end = strings.IndexByte(data[pos:next], '>'); -1 == end {
+				end = next
+			} else {
+				end += pos + 1
+			}
+
+			name = strings.ToLower(strings.TrimSpace(tag.name))
+			if "" == name || '!' == name[0] || '?' == name[0] {
+				break
+			}
+			if "close" == tag.category {
+				// script 与 style 中的内容不是标签
+				if "" == raw || raw == name {
+					raw = ""
+					r.closeTag(opt, element, index, pos, name, &stack)
+				}
+			} else if "" == raw && "start" == tag.category && !voidTags[name] {
+				stack = append(stack, &lintTag{name: name, pos: pos})
+				if "script" == name || "style" == name {
+					raw = name
+				}
+			}
+		}
+
+		if "" != raw {
+			continue
+		}
+		for idx := strings.IndexByte(data[end:next], '<'); -1 != idx; idx = strings.IndexByte(data[end:next], '<') {
+			// 解析时未结束的注释与之后的内容作为一段丢弃
+			if end += idx; strings.HasPrefix(data[end:], "<!--") {
+				r.add(opt, element, index, end, "unclosed-tag", "注释未关闭")
+
+				break
+			}
+
+			r.add(opt, element, index, end, "stray-lt", "多余的 <")
+			end++
+		}
+	}
+
+	for _, v := range stack {
+		r.add(opt, element, index, v.pos, "unclosed-tag", "标签 <"+v.name+"> 未关闭")
+	}
+}
+
+// closeTag 检查结束标签与标签栈
+func (r *LintReport) closeTag(opt *LintOption, element *Entry, index int, pos int, name string, stack *[]*lintTag) {
+	var top = len(*stack) - 1
+
+	if top >= 0 && name == (*stack)[top].name {
+		*stack = (*stack)[:top]
+
+		return
+	}
+
+	for k := top - 1; k >= 0; k-- {
+		if name == (*stack)[k].name {
+			r.add(opt, element, index, pos, "misnested-tag", "结束标签 </"+name+"> 与未关闭的 <"+(*stack)[top].name+"> 交叉嵌套")
+			*stack = (*stack)[:k]
+
+			return
+		}
+	}
+
+	r.add(opt, element, index, pos, "unmatched-close", "多余的结束标签 </"+name+">")
+}
+
+// Save 按格式保存检查报告
+func (r *LintReport) Save(file string, format string) error {
+	var err error
+	var fp *os.File
+	var buf *bufio.Writer
+	var encoder *json.Encoder
+	var writer *csv.Writer
+
+	if fp, err = os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	buf = bufio.NewWriter(fp)
+	if "json" == format {
+		encoder = json.NewEncoder(buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "    ")
+		if err = encoder.Encode(r); nil != err {
+			return err
+		}
+
+		return buf.Flush()
+	}
+
+	writer = csv.NewWriter(buf)
+	_ = writer.Write([]string{"index", "word", "offset", "severity", "rule", "message"})
+	for _, v := range r.Issues {
+		_ = writer.Write([]string{strconv.Itoa(v.Index), v.Word, strconv.FormatInt(v.Offset, 10), v.Severity, v.Rule, v.Message})
+	}
+	writer.Flush()
+	if err = writer.Error(); nil != err {
+		return err
+	}
+
+	return buf.Flush()
+}
+
+// lintMdict 检查词典源文件，问题数超过阈值时返回错误
+func lintMdict(cfg string) error {
+	var num int
+	var err error
+	var offset int64
+	var chunk []byte
+	var element *Entry
+	var reader *EntryReader
+	var opt = new(LintOption)
+	var report = &LintReport{Issues: make([]*LintIssue, 0, 100)}
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if reader, err = OpenEntryReader(opt.Input); nil != err {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		if chunk, offset, err = reader.Next(); nil != err {
+			break
+		}
+
+		report.Entries++
+		if element = parseBody(chunk, 0, len(chunk)); nil == element {
+			element = &Entry{word: strings.TrimSpace(string(chunk))}
+		}
+
+		element.offset = offset
+		report.lintEntry(opt, element, reader.Index(), string(chunk))
+	}
+	if io.EOF != err {
+		return err
+	}
+
+	fmt.Println("entries:", report.Entries, "errors:", report.Errors, "warnings:", report.Warnings)
+	if err = report.Save(opt.Report, opt.Format); nil != err {
+		return err
+	}
+
+	if num = report.Errors; "warning" == opt.FailOn {
+		num += report.Warnings
+	}
+	if num > opt.Threshold {
+		return errors.New("发现 " + strconv.Itoa(num) + " 个问题，超过阈值 " + strconv.Itoa(opt.Threshold))
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+)
+
+// errLzoCorrupt LZO 数据损坏
+var errLzoCorrupt = errors.New("LZO 数据已损坏")
+
+// lzo1xDecompress 解压 LZO1X 数据，早期 MDict 词典的数据块使用此压缩算法
+//
+// 实现思路：
+//
+//	按 minilzo 的 lzo1x_decompress_safe 流程展开为状态机：
+//	读取指令字节，根据其取值区间拷贝字面量或者从已输出的内容中回溯拷贝，
+//	直到遇到结束标记 0x11 0x00 0x00
+func lzo1xDecompress(in []byte, size int) ([]byte, error) {
+	const (
+		stateLoop = iota
+		stateFirstLiteral
+		stateMatch
+		stateMatchDone
+		stateMatchNext
+	)
+
+	var t, pos, ip, state int
+	var length = len(in)
+	var out = make([]byte, 0, size)
+
+	// 读取一个字节
+	var next = func() (int, error) {
+		if ip >= length {
+			return 0, errLzoCorrupt
+		}
+
+		ip++
+
+		return int(in[ip-1]), nil
+	}
+
+	// 读取变长的长度值
+	var extend = func(t int, base int) (int, error) {
+		for {
+			if ip >= length {
+				return 0, errLzoCorrupt
+			}
+			if 0 != in[ip] {
+				break
+			}
+
+			t += 255
+			ip++
+		}
+
+		n, err := next()
+
+		return t + base + n, err
+	}
+
+	// 拷贝字面量
+	var literal = func(n int) error {
+		if ip+n > length {
+			return errLzoCorrupt
+		}
+
+		out = append(out, in[ip:ip+n]...)
+		ip += n
+
+		return nil
+	}
+
+	// 从已输出的内容中回溯拷贝，源区间可能与目标重叠，需要逐字节拷贝
+	var match = func(from int, n int) error {
+		if from < 0 || from >= len(out) {
+			return errLzoCorrupt
+		}
+
+		for i := 0; i < n; i++ {
+			out = append(out, out[from+i])
+		}
+
+		return nil
+	}
+
+	var err error
+	var n int
+
+	if length > 0 && in[0] > 17 {
+		ip++
+		t = int(in[0]) - 17
+		if t < 4 {
+			state = stateMatchNext
+		} else {
+			if err = literal(t); nil != err {
+				return nil, err
+			}
+
+			state = stateFirstLiteral
+		}
+	}
+
+	for {
+		switch state {
+		case stateLoop:
+			if t, err = next(); nil != err {
+				return nil, err
+			}
+			if t >= 16 {
+				state = stateMatch
+
+				continue
+			}
+			if 0 == t {
+				if t, err = extend(t, 15); nil != err {
+					return nil, err
+				}
+			}
+			if err = literal(t + 3); nil != err {
+				return nil, err
+			}
+
+			state = stateFirstLiteral
+		case stateFirstLiteral:
+			if t, err = next(); nil != err {
+				return nil, err
+			}
+			if t >= 16 {
+				state = stateMatch
+
+				continue
+			}
+			if n, err = next(); nil != err {
+				return nil, err
+			}
+			if err = match(len(out)-(1+0x0800)-(t>>2)-(n<<2), 3); nil != err {
+				return nil, err
+			}
+
+			state = stateMatchDone
+		case stateMatch:
+			if t >= 64 {
+				if n, err = next(); nil != err {
+					return nil, err
+				}
+
+				pos = len(out) - 1 - ((t >> 2) & 7) - (n << 3)
+				t = (t >> 5) - 1
+			} else if t >= 32 {
+				if t &= 31; 0 == t {
+					if t, err = extend(t, 31); nil != err {
+						return nil, err
+					}
+				}
+				if ip+2 > length {
+					return nil, errLzoCorrupt
+				}
+
+				pos = len(out) - 1 - ((int(in[ip]) | int(in[ip+1])<<8) >> 2)
+				ip += 2
+			} else if t >= 16 {
+				pos = len(out) - ((t & 8) << 11)
+				if t &= 7; 0 == t {
+					if t, err = extend(t, 7); nil != err {
+						return nil, err
+					}
+				}
+				if ip+2 > length {
+					return nil, errLzoCorrupt
+				}
+
+				pos -= (int(in[ip]) | int(in[ip+1])<<8) >> 2
+				ip += 2
+				if pos == len(out) {
+					if len(out) != size && size > 0 {
+						return out, errors.New("LZO 解压后的数据长度不正确")
+					}
+
+					return out, nil
+				}
+
+				pos -= 0x4000
+			} else {
+				if n, err = next(); nil != err {
+					return nil, err
+				}
+				if err = match(len(out)-1-(t>>2)-(n<<2), 2); nil != err {
+					return nil, err
+				}
+
+				state = stateMatchDone
+
+				continue
+			}
+
+			if err = match(pos, t+2); nil != err {
+				return nil, err
+			}
+
+			state = stateMatchDone
+		case stateMatchDone:
+			if ip < 2 {
+				return nil, errLzoCorrupt
+			}
+			if t = int(in[ip-2]) & 3; 0 == t {
+				state = stateLoop
+			} else {
+				state = stateMatchNext
+			}
+		case stateMatchNext:
+			if err = literal(t); nil != err {
+				return nil, err
+			}
+			if t, err = next(); nil != err {
+				return nil, err
+			}
+
+			state = stateMatch
+		}
+	}
+}
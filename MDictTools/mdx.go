@@ -0,0 +1,1250 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/adler32"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+)
+
+// MDictKey 词典索引
+type MDictKey struct {
+	offset int64  `label:"记录偏移"`
+	word   string `label:"词头"`
+}
+
+// MDictBlock 压缩数据块尺寸
+type MDictBlock struct {
+	compSize   int64 `label:"压缩尺寸"`
+	decompSize int64 `label:"解压尺寸"`
+}
+
+// MDict MDict 词典文件读取器
+type MDict struct {
+	isMdd     bool              `label:"是否资源文件"`
+	version   float64           `label:"引擎版本"`
+	numWidth  int               `label:"数字字节数"`
+	encrypted int               `label:"加密方式"`
+	encoding  string            `label:"文本编码"`
+	header    map[string]string `label:"词典头信息"`
+	keys      []*MDictKey       `label:"词典索引"`
+	size      int64             `label:"文件长度"`
+	pos       int64             `label:"已读取的字节数"`
+	fp        *os.File          `label:"文件句柄"`
+	reader    *bufio.Reader     `label:"文件读取器"`
+}
+
+// OpenMDict 打开 MDict 词典文件并读取头信息与索引
+func OpenMDict(file string) (*MDict, error) {
+	var err error
+	var info os.FileInfo
+	var m = &MDict{isMdd: strings.HasSuffix(strings.ToLower(file), ".mdd")}
+
+	if m.fp, err = os.Open(file); nil != err {
+		return nil, err
+	}
+	if info, err = m.fp.Stat(); nil != err {
+		m.Close()
+
+		return nil, err
+	}
+
+	m.size = info.Size()
+
+	m.reader = bufio.NewReaderSize(m.fp, 1<<20)
+	if err = m.readHeader(); nil == err {
+		err = m.readKeys()
+	}
+	if nil != err {
+		m.Close()
+
+		return nil, errors.New("解析词典文件 " + file + " 失败，" + err.Error())
+	}
+
+	return m, nil
+}
+
+// Close 关闭词典文件
+func (m *MDict) Close() {
+	if nil != m.fp {
+		_ = m.fp.Close()
+		m.fp = nil
+	}
+}
+
+// Header 返回词典头信息
+func (m *MDict) Header(name string) string {
+	return m.header[name]
+}
+
+// Keys 返回词典索引
+func (m *MDict) Keys() []*MDictKey {
+	return m.keys
+}
+
+// readBytes 读取指定长度的内容，尺寸超出文件剩余长度时在分配内存前报错，避免损坏的文件申请过多内存
+func (m *MDict) readBytes(size int64) ([]byte, error) {
+	if size < 0 || size > m.size-m.pos {
+		return nil, errors.New("数据块尺寸 " + strconv.FormatInt(size, 10) + " 超出文件剩余长度")
+	}
+
+	var data = make([]byte, size)
+	var n, err = io.ReadFull(m.reader, data)
+
+	m.pos += int64(n)
+
+	return data, err
+}
+
+// readNumber 读取一个大端序数字，2.0 版本为 8 字节，1.2 版本为 4 字节
+func (m *MDict) readNumber() (int64, error) {
+	var data, err = m.readBytes(int64(m.numWidth))
+	if nil != err {
+		return 0, err
+	}
+
+	return m.toNumber(data), nil
+}
+
+// toNumber 将大端序字节转换为数字
+func (m *MDict) toNumber(data []byte) int64 {
+	if 8 == m.numWidth {
+		return int64(binary.BigEndian.Uint64(data))
+	}
+
+	return int64(binary.BigEndian.Uint32(data))
+}
+
+// readHeader 读取词典头信息
+func (m *MDict) readHeader() error {
+	var err error
+	var size int64
+	var data, sum []byte
+	var text string
+	var attrRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+	m.numWidth = 4
+	if size, err = m.readNumber(); nil != err {
+		return err
+	}
+	if data, err = m.readBytes(size); nil != err {
+		return err
+	}
+	if sum, err = m.readBytes(4); nil != err {
+		return err
+	}
+	if adler32.Checksum(data) != binary.LittleEndian.Uint32(sum) {
+		return errors.New("词典头信息校验失败")
+	}
+
+	text = strings.TrimRight(decodeUTF16(data), "\x00")
+	m.header = make(map[string]string, 20)
+	for _, v := range attrRegex.FindAllStringSubmatch(text, -1) {
+		m.header[v[1]] = html.UnescapeString(v[2])
+	}
+
+	if m.version, err = strconv.ParseFloat(m.header["GeneratedByEngineVersion"], 64); nil != err {
+		return errors.New("无法识别的词典版本 " + m.header["GeneratedByEngineVersion"])
+	}
+	if m.version >= 3 {
+		return errors.New("暂不支持 " + m.header["GeneratedByEngineVersion"] + " 版本的词典")
+	}
+	if m.version >= 2 {
+		m.numWidth = 8
+	}
+
+	switch strings.ToLower(m.header["Encrypted"]) {
+	case "", "0", "no":
+		m.encrypted = 0
+	case "yes":
+		m.encrypted = 1
+	default:
+		if m.encrypted, err = strconv.Atoi(m.header["Encrypted"]); nil != err {
+			return errors.New("无法识别的加密方式 " + m.header["Encrypted"])
+		}
+	}
+	if 0 != m.encrypted&1 {
+		return errors.New("词典索引已使用注册码加密，暂不支持")
+	}
+
+	m.encoding = strings.ToUpper(m.header["Encoding"])
+	if m.isMdd || strings.HasPrefix(m.encoding, "UTF-16") {
+		m.encoding = "UTF-16"
+	} else if "" == m.encoding {
+		m.encoding = "UTF-8"
+	}
+	if _, ok := textEncodings[m.encoding]; !ok {
+		return errors.New("暂不支持 " + m.header["Encoding"] + " 编码的词典")
+	}
+
+	return nil
+}
+
+// readKeys 读取词典索引
+func (m *MDict) readKeys() error {
+	var err error
+	var data, info []byte
+	var blocks []*MDictBlock
+	var num int
+	var numBlocks, numEntries, infoDecompSize, infoSize, blockSize int64
+
+	if m.version >= 2 {
+		num = 5
+	} else {
+		num = 4
+	}
+	if data, err = m.readBytes(int64(num * m.numWidth)); nil != err {
+		return err
+	}
+	if m.version >= 2 {
+		var sum []byte
+		if sum, err = m.readBytes(4); nil != err {
+			return err
+		}
+		if adler32.Checksum(data) != binary.BigEndian.Uint32(sum) {
+			return errors.New("词典索引头校验失败")
+		}
+	}
+
+	numBlocks = m.toNumber(data[0:])
+	numEntries = m.toNumber(data[m.numWidth:])
+	if m.version >= 2 {
+		infoDecompSize = m.toNumber(data[m.numWidth*2:])
+		infoSize = m.toNumber(data[m.numWidth*3:])
+		blockSize = m.toNumber(data[m.numWidth*4:])
+	} else {
+		infoSize = m.toNumber(data[m.numWidth*2:])
+		blockSize = m.toNumber(data[m.numWidth*3:])
+	}
+
+	if info, err = m.readBytes(infoSize); nil != err {
+		return err
+	}
+	if m.version >= 2 {
+		if 0 != m.encrypted&2 {
+			info = mdxDecrypt(info)
+		}
+		if info, err = decodeMDictBlock(info, infoDecompSize); nil != err {
+			return errors.New("解压索引信息失败，" + err.Error())
+		}
+	}
+	if blocks, err = m.parseKeyBlockInfo(info); nil != err {
+		return err
+	}
+	if int64(len(blocks)) != numBlocks {
+		return errors.New("索引块数量不正确")
+	}
+
+	if numEntries < 0 {
+		return errors.New("索引数量不正确")
+	} else if numEntries > 100000 {
+		m.keys = make([]*MDictKey, 0, 100000)
+	} else {
+		m.keys = make([]*MDictKey, 0, numEntries)
+	}
+	for _, block := range blocks {
+		blockSize -= block.compSize
+		if data, err = m.readBytes(block.compSize); nil != err {
+			return err
+		}
+		if data, err = decodeMDictBlock(data, block.decompSize); nil != err {
+			return errors.New("解压索引块失败，" + err.Error())
+		}
+
+		m.keys = append(m.keys, m.splitKeyBlock(data)...)
+	}
+	if 0 != blockSize || int64(len(m.keys)) != numEntries {
+		return errors.New("索引数量不正确")
+	}
+
+	return nil
+}
+
+// parseKeyBlockInfo 解析索引块信息
+func (m *MDict) parseKeyBlockInfo(info []byte) ([]*MDictBlock, error) {
+	var pos, size, unit, term int
+	var length = len(info)
+	var blocks = make([]*MDictBlock, 0, 100)
+
+	unit = m.charWidth()
+	if m.version >= 2 {
+		term = 1
+	}
+
+	// 读取首尾词头的长度并跳过词头
+	var skipText = func() bool {
+		if m.version >= 2 {
+			if pos+2 > length {
+				return false
+			}
+
+			size = int(binary.BigEndian.Uint16(info[pos:]))
+			pos += 2
+		} else {
+			if pos+1 > length {
+				return false
+			}
+
+			size = int(info[pos])
+			pos++
+		}
+
+		pos += (size + term) * unit
+
+		return pos <= length
+	}
+
+	for pos < length {
+		if pos += m.numWidth; pos > length {
+			break
+		}
+		if !skipText() || !skipText() || pos+m.numWidth*2 > length {
+			return nil, errors.New("索引块信息已损坏")
+		}
+
+		blocks = append(blocks, &MDictBlock{
+			compSize:   m.toNumber(info[pos:]),
+			decompSize: m.toNumber(info[pos+m.numWidth:]),
+		})
+
+		pos += m.numWidth * 2
+	}
+
+	return blocks, nil
+}
+
+// splitKeyBlock 拆分索引块为索引
+func (m *MDict) splitKeyBlock(data []byte) []*MDictKey {
+	var pos, end int
+	var offset int64
+	var length = len(data)
+	var unit = m.charWidth()
+	var keys = make([]*MDictKey, 0, 100)
+
+	for pos+m.numWidth < length {
+		offset = m.toNumber(data[pos:])
+		pos += m.numWidth
+
+		for end = pos; end+unit <= length; end += unit {
+			if 0 == data[end] && (1 == unit || 0 == data[end+1]) {
+				break
+			}
+		}
+		if end > length {
+			end = length
+		}
+
+		keys = append(keys, &MDictKey{offset: offset, word: m.decodeText(data[pos:end])})
+		pos = end + unit
+	}
+
+	return keys
+}
+
+// charWidth 返回编码单个字符单元的字节数
+func (m *MDict) charWidth() int {
+	if "UTF-16" == m.encoding {
+		return 2
+	}
+
+	return 1
+}
+
+// decodeText 将词典编码的文本转换为 UTF-8
+func (m *MDict) decodeText(data []byte) string {
+	if "UTF-16" == m.encoding {
+		return decodeUTF16(data)
+	}
+	if enc := textEncodings[m.encoding]; nil != enc {
+		if text, err := enc.NewDecoder().Bytes(data); nil == err {
+			return string(text)
+		}
+	}
+
+	return string(data)
+}
+
+// Walk 依次读取词典记录，每个记录回调一次
+//
+// 记录块按顺序解压，只缓存尚未读完的数据，内存占用与单个记录块大小相当
+func (m *MDict) Walk(fn func(key *MDictKey, record []byte) error) error {
+	var err error
+	var idx, cut int
+	var base, end, total int64
+	var data, pending []byte
+	var blocks []*MDictBlock
+	var numBlocks, numEntries, infoSize int64
+
+	if numBlocks, err = m.readNumber(); nil != err {
+		return err
+	}
+	if numEntries, err = m.readNumber(); nil != err {
+		return err
+	}
+	if infoSize, err = m.readNumber(); nil != err {
+		return err
+	}
+	if _, err = m.readNumber(); nil != err {
+		return err
+	}
+	if numEntries != int64(len(m.keys)) || numBlocks < 0 || infoSize > m.size-m.pos || infoSize != numBlocks*int64(m.numWidth)*2 {
+		return errors.New("记录块信息不正确")
+	}
+
+	blocks = make([]*MDictBlock, numBlocks)
+	for k := range blocks {
+		blocks[k] = new(MDictBlock)
+		if blocks[k].compSize, err = m.readNumber(); nil != err {
+			return err
+		}
+		if blocks[k].decompSize, err = m.readNumber(); nil != err {
+			return err
+		}
+
+		total += blocks[k].decompSize
+	}
+
+	for _, block := range blocks {
+		if data, err = m.readBytes(block.compSize); nil != err {
+			return err
+		}
+		if data, err = decodeMDictBlock(data, block.decompSize); nil != err {
+			return errors.New("解压记录块失败，" + err.Error())
+		}
+
+		pending = append(pending, data...)
+		for idx < len(m.keys) {
+			if idx+1 < len(m.keys) {
+				end = m.keys[idx+1].offset
+			} else {
+				end = total
+			}
+			if end > base+int64(len(pending)) {
+				break
+			}
+			if m.keys[idx].offset < base || end < m.keys[idx].offset {
+				return errors.New("词条 [" + m.keys[idx].word + "] 的记录偏移不正确")
+			}
+			if err = fn(m.keys[idx], pending[m.keys[idx].offset-base:end-base]); nil != err {
+				return err
+			}
+
+			idx++
+		}
+
+		if idx < len(m.keys) {
+			cut = int(m.keys[idx].offset - base)
+		} else {
+			cut = len(pending)
+		}
+		if cut > 0 {
+			pending = append(make([]byte, 0, len(pending)-cut), pending[cut:]...)
+			base += int64(cut)
+		}
+	}
+
+	if idx != len(m.keys) {
+		return errors.New("词典记录不完整")
+	}
+
+	return nil
+}
+
+// decodeMDictBlock 解压数据块
+//
+// 数据块格式：4 字节压缩方式 + 4 字节 adler32 校验值 + 压缩内容
+// 压缩方式 0 为不压缩，1 为 LZO，2 为 zlib
+func decodeMDictBlock(block []byte, size int64) ([]byte, error) {
+	var err error
+	var data []byte
+
+	if len(block) < 8 {
+		return nil, errors.New("数据块不完整")
+	}
+
+	switch binary.LittleEndian.Uint32(block) {
+	case 0:
+		data = block[8:]
+	case 1:
+		data, err = lzo1xDecompress(block[8:], int(size))
+	case 2:
+		var zr io.ReadCloser
+		if zr, err = zlib.NewReader(bytes.NewReader(block[8:])); nil == err {
+			data, err = io.ReadAll(zr)
+			_ = zr.Close()
+		}
+	default:
+		err = fmt.Errorf("不支持的压缩方式 %x", block[0:4])
+	}
+
+	if nil != err {
+		return nil, err
+	}
+	if adler32.Checksum(data) != binary.BigEndian.Uint32(block[4:8]) {
+		return nil, errors.New("数据块校验失败")
+	}
+	if int64(len(data)) != size {
+		return nil, errors.New("数据块解压后的长度不正确")
+	}
+
+	return data, nil
+}
+
+// mdxDecrypt 解密索引块信息
+//
+// 密钥为压缩内容的校验值加上固定值 0x3695 的 RIPEMD-128 摘要
+func mdxDecrypt(block []byte) []byte {
+	var t, prev byte
+	var key []byte
+	var data []byte
+
+	if len(block) < 8 {
+		return block
+	}
+
+	key = ripemd128(append(append([]byte{}, block[4:8]...), 0x95, 0x36, 0x00, 0x00))
+	data = append([]byte{}, block...)
+	prev = 0x36
+	for i := 8; i < len(data); i++ {
+		t = (data[i] >> 4) | (data[i] << 4)
+		t = t ^ prev ^ byte(i-8) ^ key[(i-8)%len(key)]
+		prev = data[i]
+		data[i] = t
+	}
+
+	return data
+}
+
+// encodeMDictBlock 使用 zlib 压缩数据块
+func encodeMDictBlock(data []byte) []byte {
+	var buf = bytes.NewBuffer(make([]byte, 0, len(data)/2+64))
+	var zw = zlib.NewWriter(buf)
+
+	buf.Write([]byte{2, 0, 0, 0})
+	buf.Write(mdictChecksum(data, binary.BigEndian))
+
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+
+	return buf.Bytes()
+}
+
+// mdictNumber 将数字转换为指定字节数的大端序字节
+func mdictNumber(num int64, width int) []byte {
+	var data = make([]byte, 8)
+
+	binary.BigEndian.PutUint64(data, uint64(num))
+
+	return data[8-width:]
+}
+
+// mdictChecksum 返回指定字节序的 adler32 校验值
+func mdictChecksum(data []byte, order binary.ByteOrder) []byte {
+	var sum = make([]byte, 4)
+
+	order.PutUint32(sum, adler32.Checksum(data))
+
+	return sum
+}
+
+// mdictSortKey 返回词头的排序键，与 MDict 不区分大小写并忽略标点的查找规则一致
+func mdictSortKey(word string, strip bool) string {
+	word = strings.ToLower(word)
+	if strip {
+		word = strings.Map(func(r rune) rune {
+			if unicode.IsPunct(r) || unicode.IsSpace(r) || unicode.IsSymbol(r) {
+				return -1
+			}
+
+			return r
+		}, word)
+	}
+
+	return word
+}
+
+// encodeUTF16 将字符串转换为 UTF-16LE 字节
+func encodeUTF16(text string) []byte {
+	var units = utf16.Encode([]rune(text))
+	var data = make([]byte, len(units)*2)
+
+	for k, v := range units {
+		binary.LittleEndian.PutUint16(data[k*2:], v)
+	}
+
+	return data
+}
+
+// decodeUTF16 将 UTF-16LE 字节转换为字符串
+func decodeUTF16(data []byte) string {
+	var units = make([]uint16, len(data)/2)
+
+	for k := range units {
+		units[k] = binary.LittleEndian.Uint16(data[k*2:])
+	}
+
+	return string(utf16.Decode(units))
+}
+
+// MDictRecord 待写入的词典记录
+type MDictRecord struct {
+	word string                 `label:"词头"`
+	size int64                  `label:"记录长度"`
+	load func() ([]byte, error) `label:"记录读取函数"`
+}
+
+// MDictWriter MDict 2.0 词典文件写入器
+type MDictWriter struct {
+	isMdd     bool           `label:"是否资源文件"`
+	encoding  string         `label:"文本编码"`
+	keySize   int            `label:"索引块大小"`
+	blockSize int64          `label:"记录块大小"`
+	attrs     [][2]string    `label:"词典头信息"`
+	records   []*MDictRecord `label:"词典记录"`
+}
+
+// NewMDictWriter 创建词典文件写入器，资源文件的词头固定使用 UTF-16 编码
+func NewMDictWriter(isMdd bool, encoding string) *MDictWriter {
+	var w = &MDictWriter{
+		isMdd:     isMdd,
+		encoding:  strings.ToUpper(encoding),
+		keySize:   32 * 1024,
+		blockSize: 64 * 1024,
+		attrs:     make([][2]string, 0, 20),
+		records:   make([]*MDictRecord, 0, 100000),
+	}
+
+	if isMdd || strings.HasPrefix(w.encoding, "UTF-16") {
+		w.encoding = "UTF-16"
+	} else {
+		w.encoding = "UTF-8"
+	}
+
+	return w
+}
+
+// Set 设置词典头信息
+func (w *MDictWriter) Set(name string, value string) {
+	for k, v := range w.attrs {
+		if v[0] == name {
+			w.attrs[k][1] = value
+
+			return
+		}
+	}
+
+	w.attrs = append(w.attrs, [2]string{name, value})
+}
+
+// Add 添加词典记录，size 为 load 返回内容的长度
+func (w *MDictWriter) Add(word string, size int64, load func() ([]byte, error)) {
+	w.records = append(w.records, &MDictRecord{word: word, size: size, load: load})
+}
+
+// encodeText 将文本转换为词典编码
+func (w *MDictWriter) encodeText(text string) []byte {
+	if "UTF-16" == w.encoding {
+		return encodeUTF16(text)
+	}
+
+	return []byte(text)
+}
+
+// textSize 返回文本转换为词典编码后的长度
+func (w *MDictWriter) textSize(text string) int64 {
+	var size int64
+
+	if "UTF-16" != w.encoding {
+		return int64(len(text))
+	}
+
+	for _, r := range text {
+		if r >= 0x10000 {
+			size += 4
+		} else {
+			size += 2
+		}
+	}
+
+	return size
+}
+
+// terminator 返回文本结束符
+func (w *MDictWriter) terminator() []byte {
+	if "UTF-16" == w.encoding {
+		return []byte{0, 0}
+	}
+
+	return []byte{0}
+}
+
+// Write 写入词典文件
+//
+// 实现思路：
+//
+//	1、按 MDict 查找规则对词头排序，计算每个记录在解压后记录流中的偏移
+//	2、按大小拆分索引块，逐块压缩后生成索引块信息
+//	3、按大小拆分记录块，先写入占位的记录块信息，再逐块读取记录压缩写入
+//	4、回到记录块信息位置写入真实的压缩尺寸
+func (w *MDictWriter) Write(file string) error {
+	var err error
+	var fp *os.File
+	var buf *bufio.Writer
+	var pos int64
+	var keyInfo, keyBlocks, infoData []byte
+	var header = bytes.NewBuffer(nil)
+	var blocks = make([][2]int, 0, 100)
+	var sizes = make([]*MDictBlock, 0, 100)
+
+	w.sort()
+	keyInfo, keyBlocks = w.buildKeys()
+
+	// 记录块按记录边界拆分，单个记录不会跨块
+	var start, end int
+	var size int64
+	for end = range w.records {
+		size += w.records[end].size
+		if size >= w.blockSize || end+1 == len(w.records) {
+			blocks = append(blocks, [2]int{start, end + 1})
+			sizes = append(sizes, &MDictBlock{decompSize: size})
+			start = end + 1
+			size = 0
+		}
+	}
+
+	if fp, err = os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	buf = bufio.NewWriterSize(fp, 1<<20)
+	header.WriteString("<")
+	if w.isMdd {
+		header.WriteString("Library_Data")
+	} else {
+		header.WriteString("Dictionary")
+	}
+	for _, v := range w.attrs {
+		header.WriteString(" " + v[0] + "=\"" + html.EscapeString(v[1]) + "\"")
+	}
+	header.WriteString("/>\r\n\x00")
+
+	infoData = encodeUTF16(header.String())
+	buf.Write(mdictNumber(int64(len(infoData)), 4))
+	buf.Write(infoData)
+	buf.Write(mdictChecksum(infoData, binary.LittleEndian))
+
+	buf.Write(keyInfo)
+	buf.Write(keyBlocks)
+
+	buf.Write(mdictNumber(int64(len(blocks)), 8))
+	buf.Write(mdictNumber(int64(len(w.records)), 8))
+	buf.Write(mdictNumber(int64(len(blocks)*16), 8))
+	if err = buf.Flush(); nil != err {
+		return err
+	}
+	if pos, err = fp.Seek(0, io.SeekCurrent); nil != err {
+		return err
+	}
+
+	buf.Write(make([]byte, 8+len(blocks)*16))
+	for k, v := range blocks {
+		var data = make([]byte, 0, sizes[k].decompSize)
+		for _, record := range w.records[v[0]:v[1]] {
+			var item []byte
+			if item, err = record.load(); nil != err {
+				return err
+			}
+			if int64(len(item)) != record.size {
+				return errors.New("词条 [" + record.word + "] 的记录长度发生了变化")
+			}
+
+			data = append(data, item...)
+		}
+
+		data = encodeMDictBlock(data)
+		sizes[k].compSize = int64(len(data))
+		size += sizes[k].compSize
+		if _, err = buf.Write(data); nil != err {
+			return err
+		}
+	}
+	if err = buf.Flush(); nil != err {
+		return err
+	}
+
+	infoData = make([]byte, 0, 8+len(sizes)*16)
+	infoData = append(infoData, mdictNumber(size, 8)...)
+	for _, v := range sizes {
+		infoData = append(infoData, mdictNumber(v.compSize, 8)...)
+		infoData = append(infoData, mdictNumber(v.decompSize, 8)...)
+	}
+	_, err = fp.WriteAt(infoData, pos)
+
+	return err
+}
+
+// sort 按 MDict 的查找规则排序词头
+func (w *MDictWriter) sort() {
+	var keys = make(map[*MDictRecord]string, len(w.records))
+
+	for _, v := range w.records {
+		keys[v] = mdictSortKey(v.word, !w.isMdd)
+	}
+
+	sort.SliceStable(w.records, func(i int, j int) bool {
+		if keys[w.records[i]] == keys[w.records[j]] {
+			return w.records[i].word < w.records[j].word
+		}
+
+		return keys[w.records[i]] < keys[w.records[j]]
+	})
+}
+
+// buildKeys 生成索引区，返回索引区头信息与索引块信息，以及全部索引块
+func (w *MDictWriter) buildKeys() ([]byte, []byte) {
+	var num, count, offset int64
+	var first, last string
+	var raw, info, block, keyBlocks []byte
+	var unit = int64(len(w.terminator()))
+	var head = make([]byte, 0, 44)
+
+	// 写入索引块首尾词头，长度以字符单元计算
+	var text = func(word string) {
+		var data = w.encodeText(word)
+
+		info = append(info, mdictNumber(int64(len(data))/unit, 2)...)
+		info = append(info, data...)
+		info = append(info, w.terminator()...)
+	}
+
+	// 压缩当前索引块并记录索引块信息
+	var flush = func() {
+		if 0 == num {
+			return
+		}
+
+		block = encodeMDictBlock(raw)
+		info = append(info, mdictNumber(num, 8)...)
+		text(first)
+		text(last)
+		info = append(info, mdictNumber(int64(len(block)), 8)...)
+		info = append(info, mdictNumber(int64(len(raw)), 8)...)
+		keyBlocks = append(keyBlocks, block...)
+
+		count++
+		num = 0
+		raw = raw[:0]
+	}
+
+	for _, record := range w.records {
+		if 0 == num {
+			first = record.word
+		}
+
+		last = record.word
+		raw = append(raw, mdictNumber(offset, 8)...)
+		raw = append(raw, w.encodeText(record.word)...)
+		raw = append(raw, w.terminator()...)
+		offset += record.size
+		num++
+
+		if len(raw) >= w.keySize {
+			flush()
+		}
+	}
+	flush()
+
+	block = encodeMDictBlock(info)
+	head = append(head, mdictNumber(count, 8)...)
+	head = append(head, mdictNumber(int64(len(w.records)), 8)...)
+	head = append(head, mdictNumber(int64(len(info)), 8)...)
+	head = append(head, mdictNumber(int64(len(block)), 8)...)
+	head = append(head, mdictNumber(int64(len(keyBlocks)), 8)...)
+	head = append(head, mdictChecksum(head, binary.BigEndian)...)
+
+	return append(head, block...), keyBlocks
+}
+
+// MdxOption MDict 词典解包与打包选项
+type MdxOption struct {
+	Input       string `label:"输入文件"`
+	Output      string `label:"输出文件"`
+	Style       string `label:"Style文件"`
+	Title       string `label:"词典标题"`
+	Description string `label:"词典描述"`
+	Encoding    string `label:"词典编码"`
+}
+
+// Init 初始化，ext 为默认输出文件扩展名，pack 表示从源文件生成词典
+func (o *MdxOption) Init(ext string, pack bool) error {
+	var pos int
+
+	if "" == o.Input {
+		return errors.New("输入文件属性 Input 不能为空")
+	}
+	if _, err := os.Stat(o.Input); nil != err {
+		return errors.New("输入文件 " + o.Input + " 不存在")
+	}
+
+	o.Input = filepath.Clean(o.Input)
+	pos = len(o.Input) - len(filepath.Ext(o.Input))
+	if "" == o.Output {
+		o.Output = o.Input[:pos] + ext
+	} else if o.Input == o.Output {
+		return errors.New("输入文件和输出文件不能相同")
+	}
+	if "" == o.Title {
+		o.Title = filepath.Base(o.Input[:pos])
+	}
+	if "" == o.Encoding {
+		o.Encoding = "UTF-8"
+	} else if o.Encoding = strings.ToUpper(o.Encoding); "UTF-8" != o.Encoding && "UTF-16" != o.Encoding {
+		return errors.New("词典编码 Encoding 只支持 UTF-8 与 UTF-16")
+	}
+
+	if "" == o.Style {
+		if pack {
+			o.Style = o.Input[:pos] + ".Style" + o.Input[pos:]
+			if _, err := os.Stat(o.Style); nil != err {
+				o.Style = ""
+			}
+		} else {
+			pos = len(o.Output) - len(filepath.Ext(o.Output))
+			o.Style = o.Output[:pos] + ".Style" + o.Output[pos:]
+		}
+	}
+
+	return nil
+}
+
+// unpackMdict 将 mdx 词典文件解包为词典源文件
+//
+// 实现思路：
+//
+//	1、读取词典头信息，取得版本、编码、加密方式与样式表
+//	2、读取并解压索引块，取得全部词头与记录偏移
+//	3、依次解压记录块，按 word\r\nbody\r\n</> 的格式写入源文件
+//	4、词典带有样式表时，写入与 tidy 约定一致的 .Style 文件
+func unpackMdict(cfg string) error {
+	var err error
+	var num int
+	var mdx *MDict
+	var fp *os.File
+	var buf *bufio.Writer
+	var body string
+	var opt = new(MdxOption)
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(".txt", false); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if mdx, err = OpenMDict(opt.Input); nil != err {
+		return err
+	}
+	defer mdx.Close()
+
+	fmt.Println("dict:", mdx.Header("Title"), ", version:", mdx.version, ", entries:", len(mdx.keys))
+	if fp, err = os.OpenFile(opt.Output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	buf = bufio.NewWriterSize(fp, 1<<20)
+	err = mdx.Walk(func(key *MDictKey, record []byte) error {
+		num++
+		body = strings.TrimRight(mdx.decodeText(record), "\x00\r\n")
+
+		buf.WriteString(key.word)
+		buf.WriteString("\r\n")
+		buf.WriteString(body)
+		_, err := buf.WriteString("\r\n</>\r\n")
+
+		if 0 == num%50000 {
+			fmt.Println("start processed:", num)
+		}
+
+		return err
+	})
+	if nil == err {
+		err = buf.Flush()
+	}
+	if nil == err && "" != strings.Trim(mdx.Header("StyleSheet"), "\r\n\t ") {
+		err = FilePutContents(opt.Style, []byte(mdx.Header("StyleSheet")), false)
+	}
+
+	return err
+}
+
+// packMdict 将词典源文件打包为 2.0 版本的 mdx 词典
+//
+// 实现思路：
+//
+//	1、逐条读取词典源文件，只保存词头、词条在文件中的位置与正文长度，去除目标不存在的链接
+//	2、读取 .Style 样式表，与标题、描述、编码一起写入词典头信息
+//	3、按 MDict 查找规则排序词头，生成索引块，写入记录块时再按位置从源文件读取正文，用 zlib 压缩
+func packMdict(cfg string) error {
+	var err error
+	var offset int64
+	var chunk, style []byte
+	var element *Entry
+	var reader *EntryReader
+	var src *os.File
+	var mdx *MDictWriter
+	var records []*MDictRecord
+	var missing map[string]bool
+	var isLink = make([]bool, 0, 100000)
+	var words = make(map[string]bool, 100000)
+	var links = make(map[string]string, 100)
+	var opt = new(MdxOption)
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(".mdx", true); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if "" != opt.Style {
+		if style, err = os.ReadFile(opt.Style); nil != err {
+			return err
+		}
+	}
+	if src, err = os.Open(opt.Input); nil != err {
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	mdx = NewMDictWriter(false, opt.Encoding)
+	mdx.Set("GeneratedByEngineVersion", "2.0")
+	mdx.Set("RequiredEngineVersion", "2.0")
+	mdx.Set("Encrypted", "No")
+	mdx.Set("Encoding", opt.Encoding)
+	mdx.Set("Format", "Html")
+	mdx.Set("Stripkey", "Yes")
+	mdx.Set("CreationDate", time.Now().Format("2006-1-2"))
+	mdx.Set("Compact", "Yes")
+	mdx.Set("Compat", "Yes")
+	mdx.Set("KeyCaseSensitive", "No")
+	mdx.Set("Description", opt.Description)
+	mdx.Set("Title", opt.Title)
+	mdx.Set("DataSourceFormat", "106")
+	mdx.Set("StyleSheet", strings.Trim(string(style), "\r\n\t "))
+	mdx.Set("Left2Right", "Yes")
+	mdx.Set("RegisterBy", "")
+
+	fmt.Println("split words")
+	if reader, err = OpenEntryReader(opt.Input); nil != err {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		if chunk, offset, err = reader.Next(); nil != err {
+			break
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element || "" == element.word {
+			continue
+		}
+		if "link" == strings.ToLower(element.action) {
+			links[element.word] = element.value
+		} else {
+			words[element.word] = true
+		}
+
+		isLink = append(isLink, "link" == strings.ToLower(element.action))
+		var body = string(entryBody(chunk, element)) + "\r\n"
+		mdx.Add(element.word, mdx.textSize(body)+int64(len(mdx.terminator())), sourceRecord(mdx, src, offset, len(chunk)))
+	}
+	if io.EOF != err {
+		return err
+	}
+
+	// 与 splitMdxData 一致，去除目标不存在的链接
+	missing = analyzeLinks(words, links).missing
+	records = mdx.records[:0]
+	for k, record := range mdx.records {
+		if !isLink[k] || !missing[record.word] {
+			records = append(records, record)
+		}
+	}
+	mdx.records = records
+
+	fmt.Println("write dict, entries:", len(mdx.records))
+
+	return mdx.Write(opt.Output)
+}
+
+// sourceRecord 返回从源文件读取词条正文的记录读取函数
+func sourceRecord(mdx *MDictWriter, src *os.File, offset int64, size int) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		var chunk = make([]byte, size)
+		var element *Entry
+
+		if _, err := src.ReadAt(chunk, offset); nil != err {
+			return nil, err
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element {
+			return nil, errors.New("读取词条失败，文件偏移 " + strconv.FormatInt(offset, 10))
+		}
+
+		return append(mdx.encodeText(string(entryBody(chunk, element))+"\r\n"), mdx.terminator()...), nil
+	}
+}
+
+// entryBody 返回词条正文，不含词头行
+func entryBody(data []byte, element *Entry) []byte {
+	var pos int
+	var body = bytes.TrimLeft(data[element.start:element.end], "\r\n\t ")
+
+	if pos = bytes.IndexAny(body, "\r\n"); -1 == pos {
+		return nil
+	}
+
+	return bytes.Trim(body[pos:], "\r\n\t ")
+}
+
+// unpackMdd 将 mdd 资源文件解包到目录
+//
+// 资源的词头为 \path\file 格式的相对路径，按路径还原目录结构，内容原样写入
+func unpackMdd(cfg string) error {
+	var err error
+	var num int
+	var mdd *MDict
+	var opt = new(MdxOption)
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init("", false); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if ".mdd" != strings.ToLower(filepath.Ext(opt.Input)) {
+		return errors.New("输入文件 " + opt.Input + " 不是 mdd 资源文件")
+	}
+
+	if mdd, err = OpenMDict(opt.Input); nil != err {
+		return err
+	}
+	defer mdd.Close()
+
+	fmt.Println("resource:", mdd.Header("Title"), ", version:", mdd.version, ", files:", len(mdd.keys))
+
+	return mdd.Walk(func(key *MDictKey, record []byte) error {
+		var file, err = resourcePath(opt.Output, key.word)
+		if nil != err {
+			return err
+		}
+		if err = os.MkdirAll(filepath.Dir(file), os.ModePerm); nil != err {
+			return err
+		}
+
+		if num++; 0 == num%5000 {
+			fmt.Println("start processed:", num)
+		}
+
+		return os.WriteFile(file, record, os.ModePerm)
+	})
+}
+
+// packMdd 将目录打包为 mdd 资源文件
+//
+// 目录中的文件按相对路径生成 \path\file 格式的词头，记录在写入时才读取文件内容
+func packMdd(cfg string) error {
+	var err error
+	var info os.FileInfo
+	var mdd *MDictWriter
+	var opt = new(MdxOption)
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(".mdd", true); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if info, err = os.Stat(opt.Input); nil != err || !info.IsDir() {
+		return errors.New("输入路径 " + opt.Input + " 不是目录")
+	}
+
+	mdd = NewMDictWriter(true, "UTF-16")
+	mdd.Set("GeneratedByEngineVersion", "2.0")
+	mdd.Set("RequiredEngineVersion", "2.0")
+	mdd.Set("Encrypted", "0")
+	mdd.Set("Encoding", "")
+	mdd.Set("Format", "")
+	mdd.Set("CreationDate", time.Now().Format("2006-1-2"))
+	mdd.Set("Compact", "No")
+	mdd.Set("Compat", "No")
+	mdd.Set("KeyCaseSensitive", "No")
+	mdd.Set("Stripkey", "No")
+	mdd.Set("Description", opt.Description)
+	mdd.Set("Title", opt.Title)
+
+	err = filepath.Walk(opt.Input, func(file string, info os.FileInfo, err error) error {
+		if nil != err || info.IsDir() {
+			return err
+		}
+
+		var rel string
+		if rel, err = filepath.Rel(opt.Input, file); nil != err {
+			return err
+		}
+
+		mdd.Add("\\"+strings.ReplaceAll(filepath.ToSlash(rel), "/", "\\"), info.Size(), func() ([]byte, error) {
+			return os.ReadFile(file)
+		})
+
+		return nil
+	})
+	if nil != err {
+		return err
+	}
+
+	fmt.Println("write resource, files:", len(mdd.records))
+
+	return mdd.Write(opt.Output)
+}
+
+// resourcePath 将 mdd 资源词头转换为目录下的文件路径，拒绝跳出目录的路径
+func resourcePath(dir string, word string) (string, error) {
+	var parts = make([]string, 0, 10)
+
+	for _, v := range strings.FieldsFunc(word, func(r rune) bool { return '\\' == r || '/' == r }) {
+		if "." == v {
+			continue
+		}
+		if ".." == v || strings.ContainsAny(v, "\x00:") {
+			return "", errors.New("资源路径 " + word + " 不正确")
+		}
+
+		parts = append(parts, v)
+	}
+	if 0 == len(parts) {
+		return "", errors.New("资源路径 " + word + " 不正确")
+	}
+
+	return filepath.Join(append([]string{dir}, parts...)...), nil
+}
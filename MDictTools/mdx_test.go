@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// mdxEntries 打包测试用的词条，cherry 是目标不存在的链接，打包时应去除
+var mdxEntries = [][2]string{
+	{"apple", "<div class=\"a\">apple 苹果</div>"},
+	{"Apple", "@@@LINK=apple"},
+	{"banana", "<p>banana</p>\r\n<p>香蕉 🍌</p>"},
+	{"cherry", "@@@LINK=missing"},
+	{"中文", "<b>zhōng wén</b>"},
+	{"long", strings.Repeat("<span>long entry</span>", 40)},
+}
+
+// writeMdxSource 写入词典源文件与样式表，返回源文件路径
+func writeMdxSource(t *testing.T, dir string) string {
+	var buf = new(strings.Builder)
+	var file = filepath.Join(dir, "dict.txt")
+
+	for _, v := range mdxEntries {
+		buf.WriteString(v[0] + "\r\n" + v[1] + "\r\n</>\r\n")
+	}
+	if err := os.WriteFile(file, []byte(buf.String()), 0o644); nil != err {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dict.Style.txt"), []byte("`1`<b>`2`</b>"), 0o644); nil != err {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+// writeConfig 写入入口的配置文件
+func writeConfig(t *testing.T, dir string, name string, data string) string {
+	var file = filepath.Join(dir, name)
+
+	if err := os.WriteFile(file, []byte(data), 0o644); nil != err {
+		t.Fatal(err)
+	}
+
+	return filepath.ToSlash(file)
+}
+
+// readSource 读取解包的源文件，返回词头与正文
+func readSource(t *testing.T, file string) map[string]string {
+	var data, err = os.ReadFile(file)
+	var ret = make(map[string]string, 10)
+
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	for _, v := range strings.Split(string(data), "\r\n</>\r\n") {
+		if pair := strings.SplitN(v, "\r\n", 2); 2 == len(pair) {
+			ret[pair[0]] = pair[1]
+		}
+	}
+
+	return ret
+}
+
+func TestMdxPackUnpack(t *testing.T) {
+	for _, encoding := range []string{"UTF-8", "UTF-16"} {
+		var dir = t.TempDir()
+		var src = writeMdxSource(t, dir)
+		var pack = writeConfig(t, dir, "pack.json", `{"Input": "`+filepath.ToSlash(src)+`", "Encoding": "`+encoding+`", "Title": "Test"}`)
+		var unpack = writeConfig(t, dir, "unpack.json", `{"Input": "`+filepath.ToSlash(filepath.Join(dir, "dict.mdx"))+`", "Output": "`+filepath.ToSlash(filepath.Join(dir, "out.txt"))+`"}`)
+
+		if err := packMdict(pack); nil != err {
+			t.Fatalf("%s: 打包失败，%v", encoding, err)
+		}
+		if err := unpackMdict(unpack); nil != err {
+			t.Fatalf("%s: 解包失败，%v", encoding, err)
+		}
+
+		var got = readSource(t, filepath.Join(dir, "out.txt"))
+		for _, v := range mdxEntries {
+			if "@@@LINK=missing" == v[1] {
+				if _, ok := got[v[0]]; ok {
+					t.Errorf("%s: 目标不存在的链接 %s 没有去除", encoding, v[0])
+				}
+			} else if got[v[0]] != v[1] {
+				t.Errorf("%s: 词条 %s 解包为 %q，应为 %q", encoding, v[0], got[v[0]], v[1])
+			}
+		}
+		if len(got) != len(mdxEntries)-1 {
+			t.Errorf("%s: 解包出 %d 个词条，应为 %d 个", encoding, len(got), len(mdxEntries)-1)
+		}
+		if style, _ := os.ReadFile(filepath.Join(dir, "out.Style.txt")); "`1`<b>`2`</b>" != string(style) {
+			t.Errorf("%s: 样式表解包为 %q", encoding, style)
+		}
+	}
+}
+
+// lzoLiterals 把数据编码为只有字面量的 LZO1X 数据，数据长度不能小于 4
+func lzoLiterals(data []byte) []byte {
+	var n = len(data)
+	var out = make([]byte, 0, n+n/255+8)
+
+	if n <= 238 {
+		out = append(out, byte(17+n))
+	} else {
+		out = append(out, 0)
+		for n -= 18; n > 255; n -= 255 {
+			out = append(out, 0)
+		}
+
+		out = append(out, byte(n))
+	}
+
+	out = append(out, data...)
+
+	return append(out, 0x11, 0, 0)
+}
+
+// mdxEncrypt 加密索引块信息，mdxDecrypt 的逆运算
+func mdxEncrypt(block []byte) []byte {
+	var t, prev byte
+	var key = ripemd128(append(append([]byte{}, block[4:8]...), 0x95, 0x36, 0x00, 0x00))
+	var data = append([]byte{}, block...)
+
+	prev = 0x36
+	for i := 8; i < len(data); i++ {
+		t = data[i] ^ prev ^ byte(i-8) ^ key[(i-8)%len(key)]
+		data[i] = (t >> 4) | (t << 4)
+		prev = data[i]
+	}
+
+	return data
+}
+
+// convertMdx 把 MDictWriter 生成的词典改为加密索引块信息、用 LZO 压缩记录块
+func convertMdx(t *testing.T, data []byte) []byte {
+	var buf = new(bytes.Buffer)
+	var pos = 4 + int(binary.BigEndian.Uint32(data))
+	var header = strings.Replace(decodeUTF16(data[4:pos]), `Encrypted="No"`, `Encrypted="2"`, 1)
+	var headerData = encodeUTF16(header)
+	var blocks = make([][]byte, 0, 10)
+	var infoSize, keySize, numBlocks, start int
+	var total int64
+	var sizes []byte
+
+	buf.Write(mdictNumber(int64(len(headerData)), 4))
+	buf.Write(headerData)
+	buf.Write(mdictChecksum(headerData, binary.LittleEndian))
+
+	// 索引区：5 个数字、校验值、索引块信息、索引块
+	pos += 4
+	infoSize = int(binary.BigEndian.Uint64(data[pos+24:]))
+	keySize = int(binary.BigEndian.Uint64(data[pos+32:]))
+	buf.Write(data[pos : pos+44])
+	buf.Write(mdxEncrypt(data[pos+44 : pos+44+infoSize]))
+	buf.Write(data[pos+44+infoSize : pos+44+infoSize+keySize])
+
+	// 记录区：4 个数字、记录块信息、记录块
+	start = pos + 44 + infoSize + keySize
+	numBlocks = int(binary.BigEndian.Uint64(data[start:]))
+	sizes = data[start+32 : start+32+numBlocks*16]
+	pos = start + 32 + numBlocks*16
+	for k := 0; k < numBlocks; k++ {
+		var compSize = int(binary.BigEndian.Uint64(sizes[k*16:]))
+		var block, err = decodeMDictBlock(data[pos:pos+compSize], int64(binary.BigEndian.Uint64(sizes[k*16+8:])))
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		block = append(append([]byte{1, 0, 0, 0}, mdictChecksum(block, binary.BigEndian)...), lzoLiterals(block)...)
+		blocks = append(blocks, block)
+		total += int64(len(block))
+		pos += compSize
+	}
+
+	buf.Write(data[start : start+24])
+	buf.Write(mdictNumber(total, 8))
+	for k, v := range blocks {
+		buf.Write(mdictNumber(int64(len(v)), 8))
+		buf.Write(sizes[k*16+8 : k*16+16])
+	}
+	for _, v := range blocks {
+		buf.Write(v)
+	}
+
+	return buf.Bytes()
+}
+
+// walkMdx 读取词典的全部记录
+func walkMdx(t *testing.T, file string) (*MDict, map[string]string) {
+	var ret = make(map[string]string, 100)
+	var mdx, err = OpenMDict(file)
+
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer mdx.Close()
+
+	if err = mdx.Walk(func(key *MDictKey, record []byte) error {
+		ret[key.word] = mdx.decodeText(record)
+
+		return nil
+	}); nil != err {
+		t.Fatal(err)
+	}
+
+	return mdx, ret
+}
+
+func TestMdxEncryptedLzo(t *testing.T) {
+	for _, encoding := range []string{"UTF-8", "UTF-16"} {
+		var dir = t.TempDir()
+		var plain = filepath.Join(dir, "plain.mdx")
+		var converted = filepath.Join(dir, "converted.mdx")
+		var mdx = NewMDictWriter(false, encoding)
+
+		// 记录块很小，短的记录块与超过 238 字节的记录块分别使用两种字面量长度编码
+		mdx.blockSize = 100
+		mdx.keySize = 64
+		mdx.Set("GeneratedByEngineVersion", "2.0")
+		mdx.Set("Encrypted", "No")
+		mdx.Set("Encoding", encoding)
+		for k := 0; k < 50; k++ {
+			var body = []byte(mdx.encodeText("<p>entry " + strconv.Itoa(k) + " " + strings.Repeat("词", k*3) + "</p>"))
+			body = append(body, mdx.terminator()...)
+			mdx.Add("word"+strconv.Itoa(k), int64(len(body)), func() ([]byte, error) {
+				return body, nil
+			})
+		}
+		if err := mdx.Write(plain); nil != err {
+			t.Fatal(err)
+		}
+
+		var data, err = os.ReadFile(plain)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(converted, convertMdx(t, data), 0o644); nil != err {
+			t.Fatal(err)
+		}
+
+		var _, want = walkMdx(t, plain)
+		var dict, got = walkMdx(t, converted)
+		if 2 != dict.encrypted {
+			t.Errorf("%s: 加密方式为 %d，应为 2", encoding, dict.encrypted)
+		}
+		if len(got) != 50 || len(want) != 50 {
+			t.Fatalf("%s: 读取出 %d、%d 个词条，应为 50 个", encoding, len(want), len(got))
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s: 词条 %s 读取为 %q，应为 %q", encoding, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestLzoDecompressLiterals(t *testing.T) {
+	for _, n := range []int{4, 17, 238, 239, 272, 273, 274, 1000} {
+		var data = bytes.Repeat([]byte("abcdefg"), n/7+1)[:n]
+		var got, err = lzo1xDecompress(lzoLiterals(data), n)
+
+		if nil != err || !bytes.Equal(got, data) {
+			t.Errorf("长度 %d 的字面量解压失败，%v", n, err)
+		}
+	}
+}
+
+func TestLzoDecompressMatches(t *testing.T) {
+	// 按 LZO1X 格式手工编码的数据，每行一条指令，覆盖各种回溯拷贝指令
+	var data = bytes.Join([][]byte{
+		{0x19}, []byte("abcdefgh"), // 首字节：8 个字面量
+		{0x5e, 0x00}, []byte("XY"), // 64～127：距离 8 拷贝 3 字节，之后 2 个字面量
+		{0x05, 0x00}, []byte("Z"), // 0～15，前面有字面量：距离 2 拷贝 2 字节，之后 1 个字面量
+		{0xb0, 0x01},           // 128～255：距离 13 拷贝 6 字节
+		{0x01}, []byte("1234"), // 0～15，前面没有字面量：4 个字面量
+		{0x20, 0x07, 0x03, 0x00}, []byte("!?#"), // 32～63：扩展长度，距离 1 拷贝 40 字节，源区间与输出重叠
+		{0x04, 0x00},                                 // 0～15，前面有字面量：距离 2 拷贝 2 字节
+		{0x00, 0x02}, []byte("ABCDEFGHIJKLMNOPQRST"), // 0～15，扩展长度：20 个字面量
+		append(append([]byte{0x20}, make([]byte, 156)...), 0xbb, 0x4c, 0x00), // 32～63：长度扩展 156 个 0，距离 20 拷贝 40000 字节
+		{0x01}, []byte("wxyz"), // 4 个字面量
+		{0x09, 0x05}, []byte("m"), // 0～15，紧跟字面量指令：距离 2071 拷贝 3 字节，之后 1 个字面量
+		{0x13, 0xa0, 0x0f},     // 16～31：距离 17384 拷贝 5 字节
+		{0x01}, []byte("pqrs"), // 4 个字面量
+		{0x18, 0x03, 0xee, 0x01}, []byte("!!"), // 16～31：扩展长度，距离 32891 拷贝 12 字节，之后 2 个字面量
+		{0x11, 0x00, 0x00}, // 结束标记
+	}, nil)
+	var want = "abcdefghabcXYXYZdefgha1234" + strings.Repeat("4", 40) + "!?#?#" +
+		strings.Repeat("ABCDEFGHIJKLMNOPQRST", 2001) + "wxyzNOPmEFGHIpqrsGHIJKLMNOPQR!!"
+
+	var got, err = lzo1xDecompress(data, len(want))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("解压出 %d 字节，应为 %d 字节，结尾为 %q", len(got), len(want), got[len(got)-31:])
+	}
+
+	if _, err = lzo1xDecompress(data[:len(data)-3], len(want)); nil == err {
+		t.Errorf("缺少结束标记的数据应解压失败")
+	}
+	if _, err = lzo1xDecompress(data, len(want)+1); nil == err {
+		t.Errorf("解压后长度不正确时应报错")
+	}
+}
+
+func TestRipemd128(t *testing.T) {
+	// RIPEMD-128 公布的测试向量
+	var cases = map[string]string{
+		"":                              "cdf26213a150dc3ecb610f18f6b38b46",
+		"a":                             "86be7afa339d0fc7cfc785e72f578d33",
+		"abc":                           "c14a12199c66e4ba84636b0f69144c77",
+		"message digest":                "9e327b3d6e523062afc1132d7df9d1b8",
+		"abcdefghijklmnopqrstuvwxyz":    "fd2aa607f71dc8f510714922b371834e",
+		strings.Repeat("1234567890", 8): "3f45ef194732c2dbb2c4a2c769795fa3",
+	}
+
+	for data, want := range cases {
+		if got := hex.EncodeToString(ripemd128([]byte(data))); got != want {
+			t.Errorf("%q 的摘要为 %s，应为 %s", data, got, want)
+		}
+	}
+}
+
+func TestMdxDecryptVector(t *testing.T) {
+	// Encrypted="2" 的 zlib 索引块信息，按 readmdict 的解密算法生成，只有一个从 apple 到 banana 的索引块
+	var block, _ = hex.DecodeString("02000000709004f3c05caad4a3142735460d71ebe06506126f4010bb563ccaaae288d6081649ac9980")
+	var plain, _ = hex.DecodeString("02000000709004f378da636000036606d6c482829c540606b6a4c43c2064800213286d0b00709004f3")
+	var info, _ = hex.DecodeString("000000000000000300056170706c6500000662616e616e61000000000000000034000000000000003d")
+
+	var got = mdxDecrypt(block)
+	if !bytes.Equal(got, plain) {
+		t.Fatalf("解密为 %x，应为 %x", got, plain)
+	}
+
+	var data, err = decodeMDictBlock(got, int64(len(info)))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, info) {
+		t.Errorf("索引块信息为 %x，应为 %x", data, info)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// MergeOption 词典合并选项
+type MergeOption struct {
+	IgnoreCase  bool         `label:"词头匹配忽略大小写"`
+	IgnoreSpace bool         `label:"词头匹配忽略空白符"`
+	IgnorePunct bool         `label:"词头匹配忽略标点符号"`
+	FollowLinks bool         `label:"目标词条为链接时合并到链接指向的词条"`
+	Encoding    string       `label:"源词典与目标词典的文件编码"`
+	OutEncoding string       `label:"输出文件编码"`
+	Source      string       `label:"源词典文件"`
+	Target      string       `label:"合并到的词典文件"`
+	Output      string       `label:"输出的词典文件"`
+	Selector    string       `label:"从源词条提取内容的选择器"`
+	Anchor      string       `label:"目标词条中插入位置的选择器"`
+	Position    string       `label:"插入位置"`
+	SourceOnly  string       `label:"只在源词典中存在的词条处理方式"`
+	Links       string       `label:"源词典链接词条处理方式"`
+	sel         *TagSelector `label:"提取内容的选择器"`
+	anchor      *TagSelector `label:"插入位置的选择器"`
+}
+
+// mergeStat 词典合并统计
+type mergeStat struct {
+	merged      int `label:"合并的词条数"`
+	noContent   int `label:"源词条没有可提取内容的词条数"`
+	noAnchor    int `label:"目标词条没有插入位置的词条数"`
+	appended    int `label:"追加的源词条数"`
+	skipped     int `label:"忽略的源词条数"`
+	linkAdded   int `label:"追加的链接数"`
+	linkDropped int `label:"丢弃的链接数"`
+}
+
+// Init 检查合并选项
+func (o *MergeOption) Init() error {
+	var err error
+	var msg = make([]string, 0, 5)
+
+	if "" == o.Source {
+		msg = append(msg, "词典源文件属性 Source 不能为空")
+	} else if _, err = os.Stat(o.Source); nil != err {
+		msg = append(msg, "词典源文件 "+o.Source+" 不存在")
+	}
+	if "" == o.Target {
+		msg = append(msg, "词典目标文件属性 Target 不能为空")
+	} else if _, err = os.Stat(o.Target); nil != err {
+		msg = append(msg, "词典目标文件 "+o.Target+" 不存在")
+	} else if "" == o.Output {
+		if pos := strings.LastIndex(o.Target, "."); pos > 0 {
+			o.Output = o.Target[:pos] + ".new" + o.Target[pos:]
+		} else {
+			o.Output = o.Target + ".new.txt"
+		}
+	}
+	if o.Output == o.Source || o.Output == o.Target {
+		msg = append(msg, "输出文件不能与源文件或目标文件相同")
+	}
+
+	if o.Encoding, err = checkEncoding("Encoding", o.Encoding); nil != err {
+		msg = append(msg, err.Error())
+	}
+	if o.OutEncoding, err = checkEncoding("OutEncoding", o.OutEncoding); nil != err {
+		msg = append(msg, err.Error())
+	}
+
+	if "" != o.Selector {
+		if o.sel, err = ParseSelector(o.Selector); nil != err {
+			msg = append(msg, "提取内容的选择器 Selector 中的"+err.Error())
+		}
+	}
+	if "" != o.Anchor {
+		if o.anchor, err = ParseSelector(o.Anchor); nil != err {
+			msg = append(msg, "插入位置的选择器 Anchor 中的"+err.Error())
+		}
+	}
+
+	if "" == o.Position {
+		o.Position = "after"
+	}
+	if "before" != o.Position && "after" != o.Position && "replace" != o.Position && "append-child" != o.Position {
+		msg = append(msg, "插入位置 Position 只能是 before、after、replace、append-child")
+	}
+	if "" == o.SourceOnly {
+		o.SourceOnly = "append"
+	}
+	if "append" != o.SourceOnly && "extract" != o.SourceOnly && "skip" != o.SourceOnly {
+		msg = append(msg, "源词条处理方式 SourceOnly 只能是 append、extract、skip")
+	}
+	if "" == o.Links {
+		o.Links = "skip"
+	}
+	if "add" != o.Links && "skip" != o.Links {
+		msg = append(msg, "链接处理方式 Links 只能是 add、skip")
+	}
+
+	if len(msg) > 0 {
+		err = errors.New(strings.Join(msg, "\n"))
+	}
+
+	return err
+}
+
+// key 返回用于匹配的词头
+func (o *MergeOption) key(word string) string {
+	if o.IgnoreCase {
+		word = strings.ToLower(word)
+	}
+	if o.IgnoreSpace || o.IgnorePunct {
+		word = strings.Map(func(r rune) rune {
+			if o.IgnoreSpace && unicode.IsSpace(r) {
+				return -1
+			}
+			if o.IgnorePunct && (unicode.IsPunct(r) || unicode.IsSymbol(r)) {
+				return -1
+			}
+
+			return r
+		}, word)
+	}
+
+	return word
+}
+
+// extract 从源词条中提取要合并的内容，没有选择器时为除词头外的全部正文
+func (o *MergeOption) extract(element *Entry, data string) string {
+	if nil == o.sel {
+		if pos := strings.IndexAny(data, "\r\n"); -1 != pos {
+			return strings.Trim(data[pos:], "\r\n\t ")
+		}
+
+		return ""
+	}
+
+	return parseBodyItem(element, data).Find(o.sel).ToString(false)
+}
+
+// insert 将内容插入到目标词条，找不到插入位置时返回 false
+//
+// 没有 Anchor 时 before 插入到正文开头，after 与 append-child 追加到正文末尾，replace 替换整个正文；
+// 有 Anchor 时只作用于第一个匹配的标签，append-child 追加为标签的最后一个子节点
+func (o *MergeOption) insert(element *Entry, data string, content string) (string, bool) {
+	var id int64
+	var tag *Tag
+	var dom *Dom
+	var word, body string
+
+	if nil == o.anchor {
+		if pos := strings.IndexAny(data, "\r\n"); -1 == pos {
+			word = data
+		} else {
+			word, body = data[:pos], strings.Trim(data[pos:], "\r\n")
+		}
+
+		switch o.Position {
+		case "before":
+			body = content + body
+		case "replace":
+			body = content
+		default:
+			body = body + content
+		}
+
+		return word + "\r\n" + body, true
+	}
+
+	dom = parseBodyItem(element, data)
+	if id = dom.Find(o.anchor).GetSubIdx(0); 0 == id {
+		return data, false
+	}
+
+	dom.index()
+	tag = dom.nodes[id]
+	switch o.Position {
+	case "before":
+		dom.Insert(content, id, false)
+	case "after":
+		// 没有结束标签的元素无法确定结束位置，不插入
+		if "self" != tag.category && tag.close <= tag.id {
+			return data, false
+		}
+
+		dom.Insert(content, id, true)
+	case "replace":
+		dom.Insert(content, id, false)
+		dom.DropTag(tag)
+	case "append-child":
+		if tag.close <= tag.id {
+			return data, false
+		}
+
+		dom.Insert(content, tag.close, false)
+	}
+
+	return dom.ToString(false), true
+}
+
+// find 返回目标词典中与词头匹配的非链接词条下标，FollowLinks 时沿链接查找，找不到时返回 -1
+func (o *MergeOption) find(word string, entries []*Entry, mapper map[string][]int) int {
+	var link = -1
+
+	for depth := 0; depth < 10; depth++ {
+		link = -1
+		for _, k := range mapper[o.key(word)] {
+			if "" == entries[k].action {
+				return k
+			} else if -1 == link && "link" == strings.ToLower(entries[k].action) {
+				link = k
+			}
+		}
+		if !o.FollowLinks || -1 == link {
+			break
+		}
+
+		word = entries[link].value
+	}
+
+	return -1
+}
+
+// mergeDict 合并词典
+//
+// 实现思路：
+//
+//	1、读取目标词典的全部词条，按匹配规则建立词头索引
+//	2、逐条读取源词典，找到目标词条后用 Selector 提取源词条内容，按 Anchor 与 Position 插入目标词条
+//	3、只在源词典中存在的词条按 SourceOnly 追加整个词条、只追加提取的内容或忽略
+//	4、源词典中的链接按 Links 忽略，或在词头不存在且链接目标存在时追加
+func mergeDict(cfg string) error {
+	var idx int
+	var ok, temp bool
+	var err error
+	var chunk []byte
+	var element *Entry
+	var reader *EntryReader
+	var data, content string
+	var stat = new(mergeStat)
+	var opt = new(MergeOption)
+	var links = make([]*Entry, 0, 100)
+	var entries = make([]*Entry, 0, 100000)
+	var container = make([]string, 0, 100000)
+	var mapper = make(map[string][]int, 100000)
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	// 源词典与目标词典不是 UTF-8 编码时先转换为 UTF-8 的临时文件
+	if opt.Target, temp, err = decodeFile(opt.Target, opt.Encoding); nil != err {
+		return err
+	}
+	if temp {
+		defer func(file string) {
+			_ = os.Remove(file)
+		}(opt.Target)
+	}
+	if opt.Source, temp, err = decodeFile(opt.Source, opt.Encoding); nil != err {
+		return err
+	}
+	if temp {
+		defer func(file string) {
+			_ = os.Remove(file)
+		}(opt.Source)
+	}
+
+	if reader, err = OpenEntryReader(opt.Target); nil != err {
+		return err
+	}
+	for {
+		if chunk, _, err = reader.Next(); nil != err {
+			break
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element {
+			continue
+		}
+
+		entries = append(entries, element)
+		container = append(container, stripSpace(string(chunk)))
+		mapper[opt.key(element.word)] = append(mapper[opt.key(element.word)], len(entries)-1)
+	}
+	reader.Close()
+	if io.EOF != err {
+		return err
+	}
+
+	if reader, err = OpenEntryReader(opt.Source); nil != err {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		if chunk, _, err = reader.Next(); nil != err {
+			break
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element {
+			continue
+		}
+		if "" != element.action {
+			if "link" == strings.ToLower(element.action) && "add" == opt.Links {
+				links = append(links, element)
+			}
+
+			continue
+		}
+
+		data = stripSpace(string(chunk))
+		if idx = opt.find(element.word, entries, mapper); -1 == idx {
+			if _, ok = mapper[opt.key(element.word)]; ok || "skip" == opt.SourceOnly {
+				stat.skipped++
+
+				continue
+			}
+			if "extract" == opt.SourceOnly {
+				if content = opt.extract(element, data); "" == content {
+					stat.noContent++
+
+					continue
+				}
+
+				data = element.word + "\r\n" + content
+			}
+
+			entries = append(entries, element)
+			container = append(container, data)
+			mapper[opt.key(element.word)] = []int{len(entries) - 1}
+			stat.appended++
+
+			continue
+		}
+
+		if content = opt.extract(element, data); "" == content {
+			stat.noContent++
+		} else if container[idx], ok = opt.insert(element, container[idx], content); ok {
+			stat.merged++
+		} else {
+			stat.noAnchor++
+		}
+	}
+	if io.EOF != err {
+		return err
+	}
+
+	for _, element = range links {
+		if _, ok = mapper[opt.key(element.word)]; ok {
+			continue
+		}
+		if _, ok = mapper[opt.key(element.value)]; !ok {
+			stat.linkDropped++
+
+			continue
+		}
+
+		entries = append(entries, element)
+		container = append(container, element.word+"\r\n@@@LINK="+element.value)
+		mapper[opt.key(element.word)] = []int{len(entries) - 1}
+		stat.linkAdded++
+	}
+
+	fmt.Println("merged:", stat.merged, "no content:", stat.noContent, "no anchor:", stat.noAnchor)
+	fmt.Println("appended:", stat.appended, "skipped:", stat.skipped, "links added:", stat.linkAdded, "links dropped:", stat.linkDropped)
+
+	if err = FilePutContents(opt.Output, []byte(strings.Join(container, "\r\n</>\r\n")), false); nil != err {
+		return err
+	}
+
+	return encodeFile(opt.Output, opt.OutEncoding)
+}
@@ -0,0 +1,824 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// cssShorthands 可以由四个方向的属性合并的简写属性
+var cssShorthands = map[string][4]string{
+	"margin":       {"margin-top", "margin-right", "margin-bottom", "margin-left"},
+	"padding":      {"padding-top", "padding-right", "padding-bottom", "padding-left"},
+	"border-width": {"border-top-width", "border-right-width", "border-bottom-width", "border-left-width"},
+	"border-style": {"border-top-style", "border-right-style", "border-bottom-style", "border-left-style"},
+	"border-color": {"border-top-color", "border-right-color", "border-bottom-color", "border-left-color"},
+}
+
+// classAttrSelectorRegex CSS 中按 class 属性匹配的属性选择器
+var classAttrSelectorRegex = regexp.MustCompile(`(?i)\[\s*class\s*([~|^$*]?=)\s*["']?([^"'\]]*)`)
+
+// MinifyOption CSS 压缩选项
+type MinifyOption struct {
+	Rename       bool     `label:"缩短类名"`
+	Encoding     string   `label:"源文件编码"`
+	CSS          string   `label:"CSS 文件"`
+	Output       string   `label:"输出的 CSS 文件"`
+	Source       string   `label:"词典源文件"`
+	SourceOutput string   `label:"输出的词典源文件"`
+	Mapping      string   `label:"类名对照表文件"`
+	SkipClass    []string `label:"不缩短的类名"`
+}
+
+// ClassMapping 缩短的类名
+type ClassMapping struct {
+	New   string `label:"新类名"`
+	Old   string `label:"原类名"`
+	Count int    `label:"源文件中的出现次数"`
+}
+
+// cssDecl CSS 声明
+type cssDecl struct {
+	name      string `label:"属性名"`
+	value     string `label:"属性值"`
+	important bool   `label:"是否为 !important"`
+}
+
+// Init 检查 CSS 压缩选项
+func (o *MinifyOption) Init() error {
+	var err error
+	var pos int
+	var msg = make([]string, 0, 2)
+
+	if "" == o.CSS {
+		return errors.New("CSS 文件属性 CSS 不能为空")
+	}
+	if _, err = os.Stat(o.CSS); nil != err {
+		return errors.New("CSS 文件 " + o.CSS + " 不存在")
+	}
+
+	pos = len(o.CSS) - len(filepath.Ext(o.CSS))
+	if "" == o.Output {
+		o.Output = o.CSS[:pos] + ".min" + o.CSS[pos:]
+	} else if o.Output == o.CSS {
+		msg = append(msg, "CSS 文件和输出文件不能相同")
+	}
+	if "" == o.Mapping {
+		o.Mapping = o.CSS[:pos] + ".classmap.json"
+	}
+	if o.Encoding, err = checkEncoding("Encoding", o.Encoding); nil != err {
+		msg = append(msg, err.Error())
+	}
+
+	if o.Rename {
+		if "" == o.Source {
+			msg = append(msg, "缩短类名时词典源文件属性 Source 不能为空")
+		} else if _, err = os.Stat(o.Source); nil != err {
+			msg = append(msg, "词典源文件 "+o.Source+" 不存在")
+		} else {
+			pos = len(o.Source) - len(filepath.Ext(o.Source))
+			if "" == o.SourceOutput {
+				o.SourceOutput = o.Source[:pos] + ".min" + o.Source[pos:]
+			} else if o.SourceOutput == o.Source {
+				msg = append(msg, "词典源文件和输出文件不能相同")
+			}
+		}
+	}
+
+	if len(msg) > 0 {
+		return errors.New(strings.Join(msg, "\n"))
+	}
+
+	return nil
+}
+
+// minifySpace 合并字符串外的空白，keep 判断相邻的两个字符之间是否需要保留一个空格
+func minifySpace(text string, keep func(prev byte, next byte) bool) string {
+	var end int
+	var space bool
+	var buf = new(strings.Builder)
+
+	for pos := 0; pos < len(text); pos = end {
+		if end = pos + 1; strings.IndexByte(" \t\r\n\f", text[pos]) >= 0 {
+			space = true
+
+			continue
+		}
+		if space && buf.Len() > 0 {
+			var out = buf.String()
+			if keep(out[len(out)-1], text[pos]) {
+				buf.WriteByte(' ')
+			}
+		}
+
+		space = false
+		if '"' == text[pos] || '\'' == text[pos] {
+			end = skipCSSString(text, pos)
+		}
+
+		buf.WriteString(text[pos:end])
+	}
+
+	return buf.String()
+}
+
+// minifySelector 压缩选择器，去掉组合符与逗号两侧的空白
+func minifySelector(text string) string {
+	return minifySpace(text, func(prev byte, next byte) bool {
+		return strings.IndexByte(">+~,(", prev) < 0 && strings.IndexByte(">+~,)", next) < 0
+	})
+}
+
+// minifyPrelude 压缩 @ 规则的条件，去掉冒号后与逗号两侧的空白
+func minifyPrelude(text string) string {
+	return minifySpace(text, func(prev byte, next byte) bool {
+		return ':' != prev && ',' != prev && ',' != next && '(' != prev && ')' != next
+	})
+}
+
+// shortenHex 把 #aabbcc 形式的颜色缩短为 #abc
+func shortenHex(value string) string {
+	var buf []byte
+	var isHex = func(c byte) bool {
+		return (c >= '0' && c <= '9') || (c|0x20 >= 'a' && c|0x20 <= 'f')
+	}
+
+	if strings.ContainsAny(value, "\"'") || strings.Contains(strings.ToLower(value), "url(") {
+		return value
+	}
+
+	buf = []byte(value)
+	for k := 0; k+6 < len(buf); k++ {
+		if '#' != buf[k] || (k+7 < len(buf) && (isHex(buf[k+7]) || '-' == buf[k+7] || '_' == buf[k+7] || buf[k+7]|0x20 >= 'g' && buf[k+7]|0x20 <= 'z')) {
+			continue
+		}
+		if !isHex(buf[k+1]) || !isHex(buf[k+2]) || !isHex(buf[k+3]) || !isHex(buf[k+4]) || !isHex(buf[k+5]) || !isHex(buf[k+6]) {
+			continue
+		}
+		if buf[k+1]|0x20 == buf[k+2]|0x20 && buf[k+3]|0x20 == buf[k+4]|0x20 && buf[k+5]|0x20 == buf[k+6]|0x20 {
+			buf = append(buf[:k+1], append([]byte{buf[k+1], buf[k+3], buf[k+5]}, buf[k+7:]...)...)
+		}
+	}
+
+	return string(buf)
+}
+
+// shortenBox 把上右下左四个方向的值缩短为最少的写法，如 0 0 0 0 缩短为 0，值中有函数时不处理
+func shortenBox(value string) string {
+	var v = strings.Fields(value)
+
+	if strings.Contains(value, "(") || len(v) < 2 || len(v) > 4 {
+		return value
+	}
+
+	switch len(v) {
+	case 2:
+		v = []string{v[0], v[1], v[0], v[1]}
+	case 3:
+		v = []string{v[0], v[1], v[2], v[1]}
+	}
+
+	switch {
+	case v[0] == v[1] && v[0] == v[2] && v[0] == v[3]:
+		return v[0]
+	case v[0] == v[2] && v[1] == v[3]:
+		return v[0] + " " + v[1]
+	case v[1] == v[3]:
+		return v[0] + " " + v[1] + " " + v[2]
+	}
+
+	return strings.Join(v, " ")
+}
+
+// parseDecls 解析声明块，去掉注释，合并值中多余的空白
+func parseDecls(body string) []*cssDecl {
+	var pos int
+	var decl *cssDecl
+	var ret = make([]*cssDecl, 0, 8)
+
+	for _, item := range splitDeclarations(stripCSSComments(body)) {
+		if pos = strings.IndexByte(item, ':'); pos < 1 {
+			continue
+		}
+
+		decl = &cssDecl{name: strings.ToLower(strings.TrimSpace(item[:pos])), value: strings.TrimSpace(item[pos+1:])}
+		if lower := strings.ToLower(decl.value); strings.HasSuffix(lower, "important") {
+			if mark := strings.LastIndexByte(decl.value, '!'); mark >= 0 && "important" == strings.TrimSpace(lower[mark+1:]) {
+				decl.important = true
+				decl.value = strings.TrimSpace(decl.value[:mark])
+			}
+		}
+
+		decl.value = minifySpace(decl.value, func(prev byte, next byte) bool {
+			return ',' != prev && ',' != next
+		})
+		if "" != decl.name && "" != decl.value {
+			ret = append(ret, decl)
+		}
+	}
+
+	return ret
+}
+
+// String 输出压缩后的声明
+func (d *cssDecl) String() string {
+	if d.important {
+		return d.name + ":" + d.value + "!important"
+	}
+
+	return d.name + ":" + d.value
+}
+
+// collapseShorthands 四个方向的属性都在同一规则中且各出现一次时合并为简写属性，已有的简写属性值缩短为最少的写法
+func collapseShorthands(decls []*cssDecl) []*cssDecl {
+	for name, longhands := range cssShorthands {
+		var first = -1
+		var important bool
+		var values [4]string
+		var found [4]int
+		var ok = true
+
+		for k := range found {
+			found[k] = -1
+		}
+		for k, decl := range decls {
+			for n, longhand := range longhands {
+				if decl.name != longhand {
+					continue
+				}
+				if first < 0 {
+					first, important = k, decl.important
+				}
+				if found[n] >= 0 || decl.important != important || strings.ContainsAny(decl.value, " (") {
+					ok = false
+				}
+
+				found[n], values[n] = k, decl.value
+			}
+			if decl.name == name && (first >= 0 || decl.important) {
+				// 简写属性在方向属性之后或带 !important 时会覆盖方向属性的值，不合并
+				ok = false
+			}
+		}
+		for n := range found {
+			if found[n] < 0 {
+				ok = false
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		var ret = make([]*cssDecl, 0, len(decls))
+		for k, decl := range decls {
+			if k == first {
+				ret = append(ret, &cssDecl{name: name, value: shortenBox(strings.Join(values[:], " ")), important: decl.important})
+			}
+			if decl.name == name || k == found[0] || k == found[1] || k == found[2] || k == found[3] {
+				continue
+			}
+
+			ret = append(ret, decl)
+		}
+
+		decls = ret
+	}
+
+	for _, decl := range decls {
+		if _, ok := cssShorthands[decl.name]; ok {
+			decl.value = shortenBox(decl.value)
+		}
+
+		decl.value = shortenHex(decl.value)
+	}
+
+	return decls
+}
+
+// dedupeDecls 去掉完全相同的重复声明，保留最后一个，不同的值可能是兼容写法，全部保留
+func dedupeDecls(decls []*cssDecl) []*cssDecl {
+	var seen = make(map[string]bool, len(decls))
+	var ret = make([]*cssDecl, 0, len(decls))
+
+	for k := len(decls) - 1; k >= 0; k-- {
+		if text := decls[k].String(); !seen[text] {
+			seen[text] = true
+			ret = append(ret, decls[k])
+		}
+	}
+	for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+		ret[i], ret[j] = ret[j], ret[i]
+	}
+
+	return ret
+}
+
+// joinDecls 输出压缩后的声明块
+func joinDecls(decls []*cssDecl) string {
+	var items = make([]string, 0, len(decls))
+
+	for _, decl := range decls {
+		items = append(items, decl.String())
+	}
+
+	return strings.Join(items, ";")
+}
+
+// minifyCSSRules 压缩规则树：去掉注释与空白，合并重复的规则与简写属性
+func minifyCSSRules(rules []*CSSRule) []*CSSRule {
+	var ret = make([]*CSSRule, 0, len(rules))
+
+	for _, rule := range rules {
+		var item = *rule
+
+		switch {
+		case "comment" == rule.kind:
+			continue
+		case "statement" == rule.kind:
+			item.prelude = minifyPrelude(rule.prelude)
+		case rule.isGroup():
+			item.prelude = minifyPrelude(rule.prelude)
+			if item.children = minifyCSSRules(rule.children); 0 == len(item.children) {
+				continue
+			}
+		case "at" == rule.kind && strings.Contains(rule.body, "{"):
+			// @keyframes 等规则的内容为普通规则列表
+			var children, _ = parseCSSBlock(rule.body, 0, false)
+
+			item.prelude = minifyPrelude(rule.prelude)
+			item.body = minifiedCSS(minifyCSSRules(children))
+		case "at" == rule.kind:
+			item.prelude = minifyPrelude(rule.prelude)
+			item.body = joinDecls(collapseShorthands(dedupeDecls(parseDecls(rule.body))))
+		default:
+			item.prelude = minifySelector(rule.prelude)
+			if item.body = joinDecls(collapseShorthands(dedupeDecls(parseDecls(rule.body)))); "" == item.body {
+				continue
+			}
+		}
+
+		ret = append(ret, &item)
+	}
+
+	return mergeCSSRules(ret)
+}
+
+// cssPropertyFamilies 名称前缀与简写属性不同的属性所属的简写属性族
+var cssPropertyFamilies = map[string]string{
+	"line-height":     "font",
+	"top":             "inset",
+	"right":           "inset",
+	"bottom":          "inset",
+	"left":            "inset",
+	"row-gap":         "gap",
+	"column-gap":      "gap",
+	"align-content":   "place",
+	"align-items":     "place",
+	"align-self":      "place",
+	"justify-content": "place",
+	"justify-items":   "place",
+	"justify-self":    "place",
+}
+
+// propertyFamily 返回属性所属的简写属性族，简写属性与其中的各项属性返回相同的值
+//
+// 按属性名第一段归类，如 margin、margin-top 与 cssShorthands 中的其他属性，border、border-left-color，
+// font、font-size，background、background-image，带浏览器前缀的属性去掉前缀后归类
+func propertyFamily(name string) string {
+	if "" == name || strings.HasPrefix(name, "--") {
+		return name
+	}
+	if '-' == name[0] {
+		if pos := strings.IndexByte(name[1:], '-'); pos >= 0 {
+			name = name[pos+2:]
+		}
+	}
+	if family, ok := cssPropertyFamilies[name]; ok {
+		return family
+	}
+	if pos := strings.IndexByte(name, '-'); pos > 0 {
+		return name[:pos]
+	}
+
+	return name
+}
+
+// declFamilies 返回声明块中属性所属的简写属性族
+func declFamilies(body string) map[string]bool {
+	var ret = make(map[string]bool, 8)
+
+	for _, decl := range parseDecls(body) {
+		ret[propertyFamily(decl.name)] = true
+	}
+
+	return ret
+}
+
+// mergeCSSRules 合并同一层级中的重复规则
+//
+// 实现思路：
+//
+//	1、选择器相同的规则把后面的声明移到前面的规则中，中间的规则设置了同一简写属性族的属性时顺序会影响层叠结果，不合并，如 margin 与 margin-top，all 与所有属性
+//	2、@media 等规则作为分界，不跨越合并
+//	3、相邻且声明完全相同的规则合并选择器，带浏览器前缀的选择器不合并，避免一个选择器无效时整条规则失效
+func mergeCSSRules(rules []*CSSRule) []*CSSRule {
+	var ret = make([]*CSSRule, 0, len(rules))
+
+	for _, rule := range rules {
+		var merged bool
+
+		for k := len(ret) - 1; k >= 0 && "rule" == rule.kind; k-- {
+			if ret[k].isGroup() {
+				break
+			}
+			if "rule" != ret[k].kind || ret[k].prelude != rule.prelude {
+				continue
+			}
+
+			var names = declFamilies(rule.body)
+			var safe = true
+			for _, between := range ret[k+1:] {
+				for name := range declFamilies(between.body) {
+					if names[name] || names["all"] || "all" == name {
+						safe = false
+					}
+				}
+			}
+			if safe {
+				ret[k].body = joinDecls(collapseShorthands(dedupeDecls(parseDecls(ret[k].body + ";" + rule.body))))
+				merged = true
+			}
+
+			break
+		}
+		if merged {
+			continue
+		}
+
+		if n := len(ret); n > 0 && "rule" == rule.kind && "rule" == ret[n-1].kind && ret[n-1].body == rule.body &&
+			!strings.Contains(rule.prelude+ret[n-1].prelude, ":-") {
+			var prev = *ret[n-1]
+
+			prev.prelude += "," + rule.prelude
+			ret[n-1] = &prev
+
+			continue
+		}
+
+		ret = append(ret, rule)
+	}
+
+	return ret
+}
+
+// minifiedCSS 输出压缩后的规则列表
+func minifiedCSS(rules []*CSSRule) string {
+	var buf = new(strings.Builder)
+
+	for _, rule := range rules {
+		switch {
+		case "statement" == rule.kind:
+			buf.WriteString(rule.prelude + ";")
+		case rule.isGroup():
+			buf.WriteString(rule.prelude + "{" + minifiedCSS(rule.children) + "}")
+		default:
+			buf.WriteString(rule.prelude + "{" + rule.body + "}")
+		}
+	}
+
+	return buf.String()
+}
+
+// walkSelectorClasses 依次处理选择器中字符串与属性选择器之外的类名，fn 返回替换后的类名
+func walkSelectorClasses(selector string, fn func(name string) string) string {
+	var end int
+	var buf = new(strings.Builder)
+
+	for pos := 0; pos < len(selector); pos = end {
+		switch selector[pos] {
+		case '"', '\'':
+			end = skipCSSString(selector, pos)
+		case '[':
+			if end = scanCSS(selector, pos, "]") + 1; end > len(selector) {
+				end = len(selector)
+			}
+		case '.':
+			var p = &selectorParser{data: selector, pos: pos + 1}
+			var name = p.parseIdent()
+
+			end = p.pos
+			if "" != name && !strings.Contains(selector[pos+1:end], "\\") {
+				buf.WriteString("." + fn(name))
+
+				continue
+			}
+		default:
+			end = pos + 1
+		}
+
+		buf.WriteString(selector[pos:end])
+	}
+
+	return buf.String()
+}
+
+// walkCSSSelectors 依次处理规则树中的选择器，fn 返回替换后的选择器
+func walkCSSSelectors(rules []*CSSRule, fn func(selector string) string) {
+	for _, rule := range rules {
+		if rule.isGroup() {
+			walkCSSSelectors(rule.children, fn)
+		} else if "rule" == rule.kind {
+			rule.prelude = fn(rule.prelude)
+		}
+	}
+}
+
+// shortClassName 返回第 n 个生成的类名，首字母为小写字母，其后为小写字母或数字
+func shortClassName(n int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	const chars = "0123456789abcdefghijklmnopqrstuvwxyz"
+	var buf = []byte{letters[n%26]}
+
+	for n /= 26; n > 0; n /= 36 {
+		n--
+		buf = append(buf, chars[n%36])
+	}
+
+	return string(buf)
+}
+
+// classMapping 生成类名对照表
+//
+// 实现思路：
+//
+//	1、CSS 选择器中的类名才缩短，SkipClass 中的类名与 [class^=x] 等属性选择器可能匹配的类名不缩短
+//	2、按源文件中的出现次数从多到少分配生成的类名，跳过源文件与 CSS 中已有的类名，生成的类名不比原类名短时不缩短
+func (o *MinifyOption) classMapping(rules []*CSSRule, counts map[string]int) map[string]*ClassMapping {
+	var seq int
+	var name string
+	var names = make([]string, 0, 100)
+	var skip = make(map[string]bool, len(o.SkipClass))
+	var cssClasses = make(map[string]bool, 100)
+	var patterns = make([][2]string, 0, 10)
+	var ret = make(map[string]*ClassMapping, 100)
+
+	for _, v := range o.SkipClass {
+		skip[v] = true
+	}
+
+	walkCSSSelectors(rules, func(selector string) string {
+		for _, v := range classAttrSelectorRegex.FindAllStringSubmatch(selector, -1) {
+			patterns = append(patterns, [2]string{v[1], strings.TrimSpace(v[2])})
+		}
+
+		return walkSelectorClasses(selector, func(class string) string {
+			cssClasses[class] = true
+
+			return class
+		})
+	})
+
+	for class := range cssClasses {
+		var keep = skip[class]
+
+		for _, v := range patterns {
+			switch v[0] {
+			case "=", "~=":
+				keep = keep || strings.Contains(" "+v[1]+" ", " "+class+" ")
+			case "|=":
+				keep = keep || class == v[1] || strings.HasPrefix(class, v[1]+"-")
+			case "^=":
+				keep = keep || strings.HasPrefix(class, v[1])
+			case "$=":
+				keep = keep || strings.HasSuffix(class, v[1])
+			case "*=":
+				keep = keep || strings.Contains(class, v[1])
+			}
+		}
+		if !keep {
+			names = append(names, class)
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+
+		return names[i] < names[j]
+	})
+	for _, class := range names {
+		for name = shortClassName(seq); cssClasses[name] || counts[name] > 0; name = shortClassName(seq) {
+			seq++
+		}
+		if len(name) >= len(class) {
+			continue
+		}
+
+		seq++
+		ret[class] = &ClassMapping{New: name, Old: class, Count: counts[class]}
+	}
+
+	return ret
+}
+
+// renameSourceClasses 按对照表替换源文件中 class 属性的类名，不含对照表中类名的词条原样复制
+func (o *MinifyOption) renameSourceClasses(mapping map[string]*ClassMapping) (int, error) {
+	var num, changed int
+	var err error
+	var chunk []byte
+	var body string
+	var dom *Dom
+	var element *Entry
+	var fp *os.File
+	var buf *bufio.Writer
+	var reader *EntryReader
+
+	if reader, err = OpenEntryReader(o.Source); nil != err {
+		return 0, err
+	}
+	defer reader.Close()
+
+	if fp, err = os.OpenFile(o.SourceOutput, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return 0, err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	buf = bufio.NewWriterSize(fp, 1<<20)
+	for {
+		if chunk, _, err = reader.Next(); nil != err {
+			break
+		}
+		if num > 0 {
+			buf.WriteString("\r\n</>\r\n")
+		}
+
+		num++
+		body = string(chunk)
+		if element = parseBody(chunk, 0, len(chunk)); nil != element && "" == element.action && classAttrRegex.Match(chunk) {
+			var renamed bool
+
+			dom = parseBodyItem(element, body)
+			for _, tag := range dom.root {
+				var attr *TagAttr
+				if !tag.state || !tag.isElement() {
+					continue
+				}
+				if attr = tag.Get("class"); nil == attr {
+					continue
+				}
+
+				var classes = strings.Fields(attr.value)
+				var rename bool
+				for k, v := range classes {
+					if item, ok := mapping[v]; ok {
+						classes[k] = item.New
+						rename = true
+					}
+				}
+				if rename {
+					tag.SetAttr("class", strings.Join(classes, " "))
+					renamed = true
+				}
+			}
+			if renamed {
+				body = dom.ToString(false)
+				changed++
+			}
+		}
+
+		buf.WriteString(body)
+	}
+	if io.EOF != err {
+		return changed, err
+	}
+
+	return changed, buf.Flush()
+}
+
+// minifyCSS 压缩 CSS 文件，可选缩短 CSS 与源文件中的类名
+//
+// 实现思路：
+//
+//	1、把 CSS 文件解析为规则树，去掉注释与多余的空白
+//	2、去掉重复的声明，四个方向的属性合并为简写属性，颜色 #aabbcc 缩短为 #abc
+//	3、合并选择器相同或声明相同的规则
+//	4、缩短类名时按源文件中的出现次数分配生成的类名，替换 CSS 选择器与源文件 class 属性中的类名，并保存类名对照表
+func minifyCSS(cfg string) error {
+	var err error
+	var temp bool
+	var data []byte
+	var size, changed int
+	var css string
+	var rules []*CSSRule
+	var list []*ClassMapping
+	var mapping map[string]*ClassMapping
+	var reader *EntryReader
+	var chunk []byte
+	var counts = make(map[string]int, 1000)
+	var opt = new(MinifyOption)
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if opt.CSS, temp, err = decodeFile(opt.CSS, ""); nil != err {
+		return err
+	}
+	if temp {
+		defer func(file string) {
+			_ = os.Remove(file)
+		}(opt.CSS)
+	}
+	if data, err = os.ReadFile(opt.CSS); nil != err {
+		return err
+	}
+
+	size = len(data)
+	rules, _ = parseCSS(string(data))
+	rules = minifyCSSRules(rules)
+
+	if opt.Rename {
+		if opt.Source, temp, err = decodeFile(opt.Source, opt.Encoding); nil != err {
+			return err
+		}
+		if temp {
+			defer func(file string) {
+				_ = os.Remove(file)
+			}(opt.Source)
+		}
+
+		if reader, err = OpenEntryReader(opt.Source); nil != err {
+			return err
+		}
+		for {
+			if chunk, _, err = reader.Next(); nil != err {
+				break
+			}
+
+			for _, v := range classAttrRegex.FindAllSubmatch(chunk, -1) {
+				for _, class := range strings.Fields(string(v[1])) {
+					counts[class]++
+				}
+			}
+		}
+		reader.Close()
+		if io.EOF != err {
+			return err
+		}
+
+		mapping = opt.classMapping(rules, counts)
+		walkCSSSelectors(rules, func(selector string) string {
+			return walkSelectorClasses(selector, func(class string) string {
+				if item, ok := mapping[class]; ok {
+					return item.New
+				}
+
+				return class
+			})
+		})
+
+		if changed, err = opt.renameSourceClasses(mapping); nil != err {
+			return err
+		}
+
+		list = make([]*ClassMapping, 0, len(mapping))
+		for _, v := range mapping {
+			list = append(list, v)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Count != list[j].Count {
+				return list[i].Count > list[j].Count
+			}
+
+			return list[i].Old < list[j].Old
+		})
+		if data, err = json.MarshalIndent(list, "", "    "); nil != err {
+			return err
+		}
+		if err = FilePutContents(opt.Mapping, data, false); nil != err {
+			return err
+		}
+	}
+
+	css = minifiedCSS(rules)
+	if err = FilePutContents(opt.Output, []byte(css), false); nil != err {
+		return err
+	}
+
+	fmt.Println("css bytes:", size, "->", len(css), "renamed classes:", len(mapping), "changed entries:", changed)
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestMergeCSSRules(t *testing.T) {
+	var cases = []struct {
+		name string
+		data string
+		want string
+	}{
+		{"merge", ".a{color:red} .b{margin:0} .a{padding:1px}", ".a{color:red;padding:1px}.b{margin:0}"},
+		{"same property", ".a{color:red} .b{color:blue} .a{margin:0}", ".a{color:red;margin:0}.b{color:blue}"},
+		{"conflict", ".a{color:red} .b{color:blue} .a{color:green}", ".a{color:red}.b{color:blue}.a{color:green}"},
+		{"longhand after shorthand", ".a{color:red} .b{margin:0} .a{margin-top:5px}", ".a{color:red}.b{margin:0}.a{margin-top:5px}"},
+		{"shorthand after longhand", ".a{color:red} .b{margin-left:1px} .a{margin:0}", ".a{color:red}.b{margin-left:1px}.a{margin:0}"},
+		{"border", ".a{color:red} .b{border:1px solid} .a{border-left-color:red}", ".a{color:red}.b{border:1px solid}.a{border-left-color:red}"},
+		{"font", ".a{color:red} .b{font:12px serif} .a{line-height:2}", ".a{color:red}.b{font:12px serif}.a{line-height:2}"},
+		{"background", ".a{color:red} .b{background:red} .a{background-image:none}", ".a{color:red}.b{background:red}.a{background-image:none}"},
+		{"prefix", ".a{color:red} .b{-webkit-margin-start:0} .a{margin:0}", ".a{color:red}.b{-webkit-margin-start:0}.a{margin:0}"},
+		{"all", ".a{color:red} .b{all:unset} .a{margin:0}", ".a{color:red}.b{all:unset}.a{margin:0}"},
+		{"media", ".a{color:red} @media print{.b{color:blue}} .a{margin:0}", ".a{color:red}@media print{.b{color:blue}}.a{margin:0}"},
+		{"same body", ".a{color:red} .b{color:red}", ".a,.b{color:red}"},
+	}
+
+	for _, v := range cases {
+		var rules, _ = parseCSS(v.data)
+		var got = minifiedCSS(minifyCSSRules(rules))
+
+		if got != v.want {
+			t.Errorf("%s: 输出 %q，应为 %q", v.name, got, v.want)
+		}
+	}
+}
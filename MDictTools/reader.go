@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+)
+
+// EntryReader 词典源文件词条读取器，按单独一行的 </> 逐条读取词条，内存占用只与单个词条大小有关
+type EntryReader struct {
+	index  int           `label:"词条序号"`
+	offset int64         `label:"已读取的字节数"`
+	fp     *os.File      `label:"文件句柄"`
+	reader *bufio.Reader `label:"文件读取器"`
+}
+
+// OpenEntryReader 打开词典源文件
+func OpenEntryReader(file string) (*EntryReader, error) {
+	var fp, err = os.Open(file)
+	if nil != err {
+		return nil, err
+	}
+
+	var r = NewEntryReader(fp)
+	r.fp = fp
+
+	return r, nil
+}
+
+// NewEntryReader 创建词条读取器
+func NewEntryReader(reader io.Reader) *EntryReader {
+	return &EntryReader{reader: bufio.NewReaderSize(reader, 1<<20)}
+}
+
+// Close 关闭词典源文件
+func (r *EntryReader) Close() {
+	if nil != r.fp {
+		_ = r.fp.Close()
+		r.fp = nil
+	}
+}
+
+// Next 读取下一个词条，返回词条内容（第一行为词头）与其在文件中的字节偏移，读完时返回 io.EOF
+func (r *EntryReader) Next() ([]byte, int64, error) {
+	var err error
+	var line []byte
+	var start = r.offset
+	var buf = new(bytes.Buffer)
+
+	for {
+		line, err = r.reader.ReadBytes('\n')
+		if 0 == r.offset && len(line) > 2 && 0xef == line[0] && 0xbb == line[1] && 0xbf == line[2] {
+			line = line[3:]
+			start = 3
+			r.offset = 3
+		}
+
+		r.offset += int64(len(line))
+		if "</>" == string(bytes.TrimRight(line, "\r\n")) {
+			if len(bytes.Trim(buf.Bytes(), "\r\n\t ")) > 0 {
+				r.index++
+
+				return bytes.TrimRight(buf.Bytes(), "\r\n"), start, nil
+			}
+
+			buf.Reset()
+			start = r.offset
+
+			continue
+		}
+
+		buf.Write(line)
+		if nil != err {
+			if io.EOF == err && len(bytes.Trim(buf.Bytes(), "\r\n\t ")) > 0 {
+				r.index++
+
+				return bytes.TrimRight(buf.Bytes(), "\r\n"), start, nil
+			}
+
+			return nil, start, err
+		}
+	}
+}
+
+// Index 返回已读取的词条数
+func (r *EntryReader) Index() int {
+	return r.index
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Replacement 关键词替换，支持字面量与带 $1 反向引用的正则表达式
+//
+// 配置中可以写为 ["原内容", "新内容"] 的字面量替换，也可以写为对象：
+//
+//	{"From": "<(/?)A\\b", "To": "<${1}a", "Regex": true, "Scope": "body"}
+type Replacement struct {
+	From     string         `label:"原内容"`
+	To       string         `label:"新内容"`
+	Regex    bool           `label:"是否正则表达式"`
+	Scope    string         `label:"作用范围"`
+	Selector string         `label:"CSS选择器"`
+	re       *regexp.Regexp `label:"正则表达式"`
+	sel      *TagSelector   `label:"选择器"`
+}
+
+// UnmarshalJSON 兼容 ["原内容", "新内容"] 格式的配置
+func (r *Replacement) UnmarshalJSON(data []byte) error {
+	type replacement Replacement
+	var pair []string
+
+	if err := json.Unmarshal(data, &pair); nil == err {
+		if 2 != len(pair) {
+			return errors.New("替换规则 " + string(data) + " 必须是两个元素的数组")
+		}
+
+		r.From, r.To = pair[0], pair[1]
+
+		return nil
+	}
+
+	return json.Unmarshal(data, (*replacement)(r))
+}
+
+// Init 检查替换规则，编译正则表达式与选择器
+//
+// Scope 取值：空为整个内容，word 为词头，body 为正文，text 为正文中选择器匹配标签内的文本
+func (r *Replacement) Init() error {
+	var err error
+
+	if "" == r.From {
+		return errors.New("替换规则的原内容 From 不能为空")
+	}
+	if "" != r.Selector {
+		if "" == r.Scope {
+			r.Scope = "text"
+		} else if "text" != r.Scope {
+			return errors.New("替换规则 " + r.From + " 的选择器只能用于 text 作用范围")
+		}
+		if r.sel, err = ParseSelector(r.Selector); nil != err {
+			return err
+		}
+	}
+	if "" != r.Scope && "word" != r.Scope && "body" != r.Scope && "text" != r.Scope {
+		return errors.New("替换规则 " + r.From + " 的作用范围 " + r.Scope + " 不正确")
+	}
+	if r.Regex {
+		if r.re, err = regexp.Compile(r.From); nil != err {
+			return errors.New("替换规则 " + r.From + " 的正则表达式不正确，" + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Replace 替换字符串
+func (r *Replacement) Replace(data string) string {
+	if nil != r.re {
+		return r.re.ReplaceAllString(data, r.To)
+	}
+
+	return strings.ReplaceAll(data, r.From, r.To)
+}
+
+// ReplaceBytes 替换字节内容
+func (r *Replacement) ReplaceBytes(data []byte) []byte {
+	if nil != r.re {
+		return r.re.ReplaceAll(data, []byte(r.To))
+	}
+
+	return bytes.ReplaceAll(data, []byte(r.From), []byte(r.To))
+}
+
+// initReplacements 检查替换规则列表，返回错误信息
+func initReplacements(name string, list []*Replacement) []string {
+	var msg = make([]string, 0, 2)
+
+	for _, v := range list {
+		if err := v.Init(); nil != err {
+			msg = append(msg, name+" 中的"+err.Error())
+		} else if strings.Contains(v.From, "</>") {
+			msg = append(msg, name+" 中的替换规则 "+strconv.Quote(v.From)+" 按词条执行，不能匹配跨越 </> 分隔行的内容")
+		}
+	}
+
+	return msg
+}
+
+// replaceAll 按顺序执行指定作用范围的替换规则
+func replaceAll(data string, list []*Replacement, scope string) string {
+	for _, v := range list {
+		if scope == v.Scope {
+			data = v.Replace(data)
+		}
+	}
+
+	return data
+}
+
+// replaceEntry 对词条执行词头与正文作用范围的替换规则，词条的第一行为词头
+func replaceEntry(data string, list []*Replacement) string {
+	var pos int
+	var word, body string
+	var hasScope bool
+
+	for _, v := range list {
+		if "word" == v.Scope || "body" == v.Scope {
+			hasScope = true
+
+			break
+		}
+	}
+	if !hasScope {
+		return data
+	}
+
+	if pos = strings.IndexAny(data, "\r\n"); -1 == pos {
+		word = data
+	} else {
+		word, body = data[:pos], data[pos:]
+	}
+
+	return replaceAll(word, list, "word") + replaceAll(body, list, "body")
+}
+
+// ReplaceText 对正文中的文本内容执行 text 作用范围的替换规则，标签本身不受影响
+func (d *Dom) ReplaceText(list []*Replacement) {
+	var matched bool
+	var parent *Tag
+
+	for _, v := range list {
+		if "text" != v.Scope {
+			continue
+		}
+
+		for k, tag := range d.root {
+			if 0 == k || !tag.state || "content" != tag.category {
+				continue
+			}
+
+			matched = nil == v.sel
+			for parent = d.Parent(tag); !matched && nil != parent; parent = d.Parent(parent) {
+				matched = d.Match(parent, v.sel)
+			}
+			if matched {
+				tag.value = v.Replace(tag.value)
+			}
+		}
+	}
+}
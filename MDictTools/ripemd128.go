@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// ripemd128 左右两条运算线的消息字下标与循环左移位数
+var (
+	ripemdRL = [64]uint8{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		7, 4, 13, 1, 10, 6, 15, 3, 12, 0, 9, 5, 2, 14, 11, 8,
+		3, 10, 14, 4, 9, 15, 8, 1, 2, 7, 0, 6, 13, 11, 5, 12,
+		1, 9, 11, 10, 0, 8, 12, 4, 13, 3, 7, 15, 14, 5, 6, 2,
+	}
+	ripemdRR = [64]uint8{
+		5, 14, 7, 0, 9, 2, 11, 4, 13, 6, 15, 8, 1, 10, 3, 12,
+		6, 11, 3, 7, 0, 13, 5, 10, 14, 15, 8, 12, 4, 9, 1, 2,
+		15, 5, 1, 3, 7, 14, 6, 9, 11, 8, 12, 2, 10, 0, 4, 13,
+		8, 6, 4, 1, 3, 11, 15, 0, 5, 12, 2, 13, 9, 7, 10, 14,
+	}
+	ripemdSL = [64]uint8{
+		11, 14, 15, 12, 5, 8, 7, 9, 11, 13, 14, 15, 6, 7, 9, 8,
+		7, 6, 8, 13, 11, 9, 7, 15, 7, 12, 15, 9, 11, 7, 13, 12,
+		11, 13, 6, 7, 14, 9, 13, 15, 14, 8, 13, 6, 5, 12, 7, 5,
+		11, 12, 14, 15, 14, 15, 9, 8, 9, 14, 5, 6, 8, 6, 5, 12,
+	}
+	ripemdSR = [64]uint8{
+		8, 9, 9, 11, 13, 15, 15, 5, 7, 7, 8, 11, 14, 14, 12, 6,
+		9, 13, 15, 7, 12, 8, 9, 11, 7, 7, 12, 7, 6, 15, 13, 11,
+		9, 7, 15, 11, 8, 6, 6, 14, 12, 13, 5, 14, 13, 13, 7, 5,
+		15, 5, 8, 11, 14, 14, 6, 14, 6, 9, 12, 9, 12, 5, 15, 8,
+	}
+	ripemdKL = [4]uint32{0x00000000, 0x5a827999, 0x6ed9eba1, 0x8f1bbcdc}
+	ripemdKR = [4]uint32{0x50a28be6, 0x5c4dd124, 0x6d703ef3, 0x00000000}
+)
+
+// ripemdF RIPEMD 的四个非线性函数
+func ripemdF(j int, x uint32, y uint32, z uint32) uint32 {
+	switch j {
+	case 0:
+		return x ^ y ^ z
+	case 1:
+		return (x & y) | (^x & z)
+	case 2:
+		return (x | ^y) ^ z
+	default:
+		return (x & z) | (y & ^z)
+	}
+}
+
+// ripemd128 计算 RIPEMD-128 摘要，MDict 用它生成索引解密密钥
+func ripemd128(data []byte) []byte {
+	var x [16]uint32
+	var i, j, round int
+	var t, al, bl, cl, dl, ar, br, cr, dr uint32
+	var h = [4]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+	var msg = make([]byte, 0, len(data)+72)
+	var sum = make([]byte, 16)
+
+	msg = append(msg, data...)
+	msg = append(msg, 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	msg = append(msg, make([]byte, 8)...)
+	binary.LittleEndian.PutUint64(msg[len(msg)-8:], uint64(len(data))<<3)
+
+	for i = 0; i < len(msg); i += 64 {
+		for j = 0; j < 16; j++ {
+			x[j] = binary.LittleEndian.Uint32(msg[i+j*4:])
+		}
+
+		al, bl, cl, dl = h[0], h[1], h[2], h[3]
+		ar, br, cr, dr = h[0], h[1], h[2], h[3]
+		for j = 0; j < 64; j++ {
+			round = j >> 4
+
+			t = bits.RotateLeft32(al+ripemdF(round, bl, cl, dl)+x[ripemdRL[j]]+ripemdKL[round], int(ripemdSL[j]))
+			al, dl, cl, bl = dl, cl, bl, t
+
+			t = bits.RotateLeft32(ar+ripemdF(3-round, br, cr, dr)+x[ripemdRR[j]]+ripemdKR[round], int(ripemdSR[j]))
+			ar, dr, cr, br = dr, cr, br, t
+		}
+
+		t = h[1] + cl + dr
+		h[1] = h[2] + dl + ar
+		h[2] = h[3] + al + br
+		h[3] = h[0] + bl + cr
+		h[0] = t
+	}
+
+	for i = 0; i < 4; i++ {
+		binary.LittleEndian.PutUint32(sum[i*4:], h[i])
+	}
+
+	return sum
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TidyRule 整理规则，按顺序作用于每个词条的 DOM 树
+type TidyRule struct {
+	Selector string          `label:"CSS选择器"`
+	Action   string          `label:"动作"`
+	Param    json.RawMessage `label:"动作参数"`
+	sel      *TagSelector    `label:"选择器"`
+	tidy     *TidyOption     `label:"Tidy 动作参数"`
+	tag      string          `label:"标签名"`
+	attrs    [][2]string     `label:"属性列表"`
+	names    []string        `label:"属性名或类名列表"`
+	pairs    []*Replacement  `label:"替换的关键词"`
+	extract  *ExtractParam   `label:"Extract 动作参数"`
+	state    *extractState   `label:"内嵌块统计"`
+}
+
+// TagParam 标签参数，用于 Rename 与 Wrap 动作
+type TagParam struct {
+	Tag   string            `label:"标签名"`
+	Attrs map[string]string `label:"标签属性"`
+}
+
+// initRules 检查整理规则，Rules 中没有 Tidy 动作时用顶层的整理参数作为第一条 Tidy 规则
+func (o *TidyOption) initRules() []string {
+	var hasTidy bool
+	var msg = make([]string, 0, 2)
+
+	for k, rule := range o.Rules {
+		if err := rule.Init(); nil != err {
+			msg = append(msg, "第 "+strconv.Itoa(k+1)+" 条整理规则"+err.Error())
+		} else if "Tidy" == rule.Action {
+			hasTidy = true
+		}
+	}
+
+	o.rules = make([]*TidyRule, 0, len(o.Rules)+1)
+	if !hasTidy {
+		o.rules = append(o.rules, &TidyRule{Action: "Tidy", tidy: o})
+	} else if len(o.Drop) > 0 || len(o.UnWrap) > 0 {
+		msg = append(msg, "Drop、UnWrap 属性不能与 Rules 中的 Tidy 规则同时使用")
+	}
+
+	o.rules = append(o.rules, o.Rules...)
+
+	return msg
+}
+
+// initDeadClass 读取 css 入口生成的概览文件，在整理规则最后加上去掉没有样式的类名的 RemoveClass 规则
+func (o *TidyOption) initDeadClass() []string {
+	var summary = new(CSSSummary)
+	var rule = &TidyRule{Action: "RemoveClass", sel: MustParseSelector("[class]")}
+
+	if "" == o.DeadClass {
+		return nil
+	}
+	if err := LoadJSON(o.DeadClass, summary); nil != err {
+		return []string{"概览文件 DeadClass 读取失败，" + err.Error()}
+	}
+	if nil == summary.Unstyled {
+		return []string{"概览文件 DeadClass 中没有 Unstyled，请用 css 入口重新生成"}
+	}
+
+	for _, v := range summary.Unstyled.Class {
+		rule.names = append(rule.names, v.Name)
+	}
+	if len(rule.names) > 0 {
+		o.rules = append(o.rules, rule)
+	}
+
+	return nil
+}
+
+// Init 解析选择器与动作参数
+func (r *TidyRule) Init() error {
+	var err error
+	var param = new(TagParam)
+
+	if "" != r.Selector {
+		if r.sel, err = ParseSelector(r.Selector); nil != err {
+			return err
+		}
+	}
+
+	switch r.Action {
+	case "Tidy":
+		r.tidy = new(TidyOption)
+		if err = r.unmarshal(r.tidy); nil != err {
+			return err
+		}
+		if msg := r.tidy.initSelectors(); len(msg) > 0 {
+			return errors.New(strings.Join(msg, "，"))
+		}
+
+		r.tidy.scope = r.sel
+
+		return nil
+	case "ReplaceText":
+		if err = r.unmarshal(&r.pairs); nil == err && 0 == len(r.pairs) {
+			err = errors.New("动作参数 Param 不能为空")
+		}
+		for _, v := range r.pairs {
+			if nil == err && "" != v.Scope+v.Selector {
+				err = errors.New("动作 ReplaceText 的替换规则不支持 Scope 与 Selector")
+			} else if nil == err {
+				err = v.Init()
+			}
+		}
+
+		return err
+	case "Extract":
+		r.extract = new(ExtractParam)
+		if err = r.unmarshal(r.extract); nil != err {
+			return err
+		}
+		if nil == r.sel {
+			r.sel = MustParseSelector("style, script")
+		}
+
+		r.state = &extractState{counts: make(map[string]int, 100), blocks: make(map[string]string, 100), shared: make(map[string]bool, 10)}
+
+		return r.extract.Init()
+	}
+
+	if nil == r.sel {
+		return errors.New("动作 " + r.Action + " 的选择器 Selector 不能为空")
+	}
+
+	switch r.Action {
+	case "Drop", "UnWrap":
+	case "Rename", "Wrap":
+		if err = r.unmarshal(param); nil != err {
+			return err
+		}
+		if r.tag = strings.ToLower(strings.TrimSpace(param.Tag)); "" == r.tag || !isTagName(r.tag) {
+			return errors.New("动作 " + r.Action + " 的标签名 Tag 不正确")
+		}
+
+		r.attrs = sortAttrs(param.Attrs)
+	case "SetAttr":
+		var attrs map[string]string
+		if err = r.unmarshal(&attrs); nil == err && 0 == len(attrs) {
+			err = errors.New("动作参数 Param 不能为空")
+		}
+
+		r.attrs = sortAttrs(attrs)
+	case "RemoveAttr", "AddClass", "RemoveClass":
+		if err = r.unmarshal(&r.names); nil == err && 0 == len(r.names) {
+			err = errors.New("动作参数 Param 不能为空")
+		}
+		if "RemoveAttr" == r.Action {
+			for k, v := range r.names {
+				r.names[k] = strings.ToLower(v)
+			}
+		}
+	default:
+		err = errors.New("不支持的动作 " + r.Action)
+	}
+
+	return err
+}
+
+// unmarshal 解析动作参数
+func (r *TidyRule) unmarshal(v interface{}) error {
+	if 0 == len(r.Param) {
+		return nil
+	}
+	if err := json.Unmarshal(r.Param, v); nil != err {
+		return errors.New("动作 " + r.Action + " 的参数 Param 解析失败，" + err.Error())
+	}
+
+	return nil
+}
+
+// Apply 对 DOM 树执行规则
+func (r *TidyRule) Apply(d *Dom, entry *Entry) {
+	var skip int64
+	var matched []*Tag
+
+	if "Tidy" == r.Action {
+		d.Tidy(entry, r.tidy)
+
+		return
+	}
+	if "Extract" == r.Action {
+		r.extractBlocks(d)
+
+		return
+	}
+	if nil == r.sel {
+		for k, tag := range d.root {
+			if k > 0 && tag.state && "content" == tag.category {
+				tag.value = replaceAll(tag.value, r.pairs, "")
+			}
+		}
+
+		return
+	}
+
+	// 先收集匹配的标签，避免修改 DOM 后影响后续匹配，删除与替换文本时跳过已匹配标签内的子标签，避免重复处理
+	for _, tag := range d.root {
+		if skip > 0 && tag.id <= skip {
+			continue
+		}
+		if tag.state && d.Match(tag, r.sel) {
+			matched = append(matched, tag)
+			if "Drop" == r.Action || "ReplaceText" == r.Action {
+				skip = d.end(tag)
+			}
+		}
+	}
+
+	for _, tag := range matched {
+		switch r.Action {
+		case "Drop":
+			d.DropTag(tag)
+		case "UnWrap":
+			d.UnWrapTag(tag)
+		case "Rename":
+			d.RenameTag(tag, r.tag)
+			for _, v := range r.attrs {
+				tag.SetAttr(v[0], v[1])
+			}
+		case "Wrap":
+			d.WrapTag(tag, r.tag, r.attrs)
+		case "SetAttr":
+			for _, v := range r.attrs {
+				tag.SetAttr(v[0], v[1])
+			}
+		case "RemoveAttr":
+			tag.StripAttr(r.names)
+		case "AddClass":
+			tag.AddClass(r.names)
+		case "RemoveClass":
+			tag.RemoveClass(r.names)
+		case "ReplaceText":
+			for _, v := range d.root {
+				if v.id > tag.id && v.id < d.end(tag) && v.state && "content" == v.category {
+					v.value = replaceAll(v.value, r.pairs, "")
+				}
+			}
+		}
+	}
+}
+
+// Apply 按顺序对 DOM 树执行整理规则
+func (d *Dom) Apply(entry *Entry, rules []*TidyRule) {
+	for _, rule := range rules {
+		rule.Apply(d, entry)
+	}
+}
+
+// end 返回标签结束位置的编号，未关闭或自关闭标签返回自身编号
+func (d *Dom) end(tag *Tag) int64 {
+	if tag.close > tag.id {
+		return tag.close
+	}
+
+	return tag.id
+}
+
+// DropTag 删除标签及其子标签
+func (d *Dom) DropTag(tag *Tag) {
+	var end = d.end(tag)
+
+	for _, v := range d.root {
+		if v.id >= tag.id && v.id <= end {
+			v.Drop()
+		}
+	}
+}
+
+// UnWrapTag 删除标签但保留其子标签
+func (d *Dom) UnWrapTag(tag *Tag) {
+	tag.Drop()
+
+	d.index()
+	if v, ok := d.nodes[tag.close]; ok && tag.close > tag.id {
+		v.Drop()
+	}
+}
+
+// RenameTag 修改标签名，同时修改结束标签
+func (d *Dom) RenameTag(tag *Tag, name string) {
+	tag.Parse()
+	tag.name = name
+	tag.dynamic = true
+
+	d.index()
+	if v, ok := d.nodes[tag.close]; ok && tag.close > tag.id {
+		v.name = name
+		v.value = "</" + name + ">"
+	}
+}
+
+// WrapTag 用新标签包裹标签
+func (d *Dom) WrapTag(tag *Tag, name string, attrs [][2]string) {
+	var pos int
+	var prev, next int64
+	var end = d.end(tag)
+	var buf = new(strings.Builder)
+
+	for pos = range d.root {
+		if d.root[pos].id == tag.id {
+			break
+		}
+	}
+	if pos > 0 {
+		prev = d.root[pos-1].id
+	}
+	for pos = range d.root {
+		if d.root[pos].id > end {
+			next = d.root[pos].id
+
+			break
+		}
+	}
+	if 0 == next {
+		next = end + 10000
+	}
+
+	// 编号间隔用完时重新编号
+	if tag.id-prev < 2 || next-end < 2 {
+		d.renumber()
+		d.WrapTag(tag, name, attrs)
+
+		return
+	}
+
+	buf.WriteString("<" + name)
+	for _, v := range attrs {
+		buf.WriteString(" " + v[0] + "=\"" + strings.ReplaceAll(v[1], "\"", "&quot;") + "\"")
+	}
+	buf.WriteString(">")
+
+	var start = &Tag{state: true, hasAttr: len(attrs) > 0, id: (prev + tag.id) / 2, parent: tag.parent, category: "start", name: name, value: buf.String()}
+	var closeTag = &Tag{state: true, id: (end + next) / 2, parent: tag.parent, category: "close", name: name, value: "</" + name + ">"}
+
+	start.close = closeTag.id
+	tag.parent = start.id
+
+	d.index()
+	if v, ok := d.nodes[tag.close]; ok && tag.close > tag.id {
+		v.parent = start.id
+	}
+
+	d.root = append(d.root, start, closeTag)
+	d.reindex()
+
+	sort.Slice(d.root, func(i int, j int) bool {
+		return d.root[i].id < d.root[j].id
+	})
+}
+
+// renumber 按顺序重新为标签编号，同时修正上级与结束标签编号
+func (d *Dom) renumber() {
+	var mapper = make(map[int64]int64, len(d.root))
+
+	for k, tag := range d.root {
+		mapper[tag.id] = int64(k+1)*10000 + 5000
+	}
+	for _, tag := range d.root {
+		tag.id = mapper[tag.id]
+		tag.parent = mapper[tag.parent]
+		tag.close = mapper[tag.close]
+	}
+
+	d.idx = int64(len(d.root))
+	d.sub = nil
+	d.reindex()
+}
+
+// sortAttrs 将属性按名称排序，保证输出稳定
+func sortAttrs(attrs map[string]string) [][2]string {
+	var ret = make([][2]string, 0, len(attrs))
+
+	for k, v := range attrs {
+		ret = append(ret, [2]string{k, v})
+	}
+
+	sort.Slice(ret, func(i int, j int) bool {
+		return ret[i][0] < ret[j][0]
+	})
+
+	return ret
+}
+
+// isTagName 是否为合法的标签名
+func isTagName(name string) bool {
+	for k, v := range name {
+		if !(v >= 'a' && v <= 'z') && !(k > 0 && ((v >= '0' && v <= '9') || '-' == v)) {
+			return false
+		}
+	}
+
+	return "" != name
+}
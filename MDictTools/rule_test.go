@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTidyRuleApply(t *testing.T) {
+	var cases = []struct {
+		selector string
+		action   string
+		param    string
+		data     string
+		want     string
+	}{
+		{"div", "ReplaceText", `[["a", "aa"]]`, "<div>a<div>a</div>a</div><p>a</p>", "<div>aa<div>aa</div>aa</div><p>a</p>"},
+		{"div", "ReplaceText", `[["a", "aa"]]`, "<div>a</div><div>a</div>", "<div>aa</div><div>aa</div>"},
+		{"p, b", "ReplaceText", `[["x", "xy"]]`, "<p>x<b>x</b></p><b>x</b>", "<p>xy<b>xy</b></p><b>xy</b>"},
+		{"", "ReplaceText", `[["a", "aa"]]`, "<div>a<div>a</div></div>", "<div>aa<div>aa</div></div>"},
+		{"div", "Drop", "", "<div>a<div>b</div></div><p>c</p>", "<p>c</p>"},
+		{".x", "AddClass", `["y"]`, `<p class="x"><b class="x">a</b></p>`, `<p class="x y"><b class="x y">a</b></p>`},
+	}
+
+	for _, v := range cases {
+		var rule = &TidyRule{Selector: v.selector, Action: v.action, Param: json.RawMessage(v.param)}
+		if err := rule.Init(); nil != err {
+			t.Fatalf("%s %s: %v", v.selector, v.action, err)
+		}
+
+		var entry = &Entry{word: "test"}
+		var dom = parseBodyItem(entry, v.data)
+		rule.Apply(dom, entry)
+		if got := dom.ToString(false); got != v.want {
+			t.Errorf("%s %s: 输出 %q，应为 %q", v.selector, v.action, got, v.want)
+		}
+	}
+}
@@ -0,0 +1,644 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TagSelector 标签选择器，对应一组以逗号分隔的 CSS 选择器
+type TagSelector struct {
+	Text  string             `label:"选择器原文"`
+	Items []*ComplexSelector `label:"选择器列表"`
+}
+
+// ComplexSelector 由组合符连接的复合选择器
+//
+// Combinators[i] 为 Parts[i] 与 Parts[i+1] 之间的组合符：' ' 后代、'>' 子元素、'+' 相邻兄弟、'~' 兄弟
+type ComplexSelector struct {
+	Parts       []*CompoundSelector `label:"复合选择器"`
+	Combinators []byte              `label:"组合符"`
+}
+
+// CompoundSelector 复合选择器，如 div.a.b[x]:first-child
+type CompoundSelector struct {
+	Tag     string            `label:"标签名"`
+	IDs     []string          `label:"ID"`
+	Classes []string          `label:"类名"`
+	Attrs   []*AttrSelector   `label:"属性选择器"`
+	Pseudos []*PseudoSelector `label:"伪类选择器"`
+}
+
+// AttrSelector 属性选择器
+type AttrSelector struct {
+	Name  string `label:"属性名"`
+	Op    string `label:"比较符"`
+	Value string `label:"属性值"`
+	Fold  bool   `label:"忽略大小写"`
+}
+
+// PseudoSelector 伪类选择器
+type PseudoSelector struct {
+	Name string       `label:"伪类名"`
+	A    int          `label:"nth 步长"`
+	B    int          `label:"nth 偏移"`
+	Not  *TagSelector `label:"否定选择器"`
+}
+
+// ParseSelector 解析 CSS 选择器，不支持或格式不正确的选择器返回错误
+func ParseSelector(text string) (*TagSelector, error) {
+	var err error
+	var p = &selectorParser{data: text}
+	var sel *TagSelector
+
+	if sel, err = p.parseList(); nil == err && p.pos < len(p.data) {
+		err = p.error("多余的字符")
+	}
+	if nil != err {
+		return nil, errors.New("选择器 " + text + " 解析失败，" + err.Error())
+	}
+
+	sel.Text = text
+
+	return sel, nil
+}
+
+// MustParseSelector 解析 CSS 选择器，解析失败时 panic，用于程序内置的选择器
+func MustParseSelector(text string) *TagSelector {
+	var sel, err = ParseSelector(text)
+	if nil != err {
+		panic(err)
+	}
+
+	return sel
+}
+
+// String 返回选择器原文
+func (s *TagSelector) String() string {
+	return s.Text
+}
+
+// selectorParser 选择器解析器
+type selectorParser struct {
+	pos  int    `label:"当前位置"`
+	data string `label:"选择器原文"`
+}
+
+// error 返回带位置信息的解析错误
+func (p *selectorParser) error(msg string) error {
+	return errors.New("位置 " + strconv.Itoa(p.pos) + " " + msg)
+}
+
+// skipSpace 跳过空白符，返回是否有空白符
+func (p *selectorParser) skipSpace() bool {
+	var start = p.pos
+
+	for p.pos < len(p.data) && strings.IndexByte(" \t\r\n\f", p.data[p.pos]) >= 0 {
+		p.pos++
+	}
+
+	return p.pos > start
+}
+
+// peek 返回当前字符，到达结尾时返回 0
+func (p *selectorParser) peek() byte {
+	if p.pos < len(p.data) {
+		return p.data[p.pos]
+	}
+
+	return 0
+}
+
+// parseList 解析逗号分隔的选择器列表
+func (p *selectorParser) parseList() (*TagSelector, error) {
+	var err error
+	var item *ComplexSelector
+	var sel = &TagSelector{Items: make([]*ComplexSelector, 0, 2)}
+
+	for {
+		p.skipSpace()
+		if item, err = p.parseComplex(); nil != err {
+			return nil, err
+		}
+
+		sel.Items = append(sel.Items, item)
+		if ',' != p.peek() {
+			return sel, nil
+		}
+
+		p.pos++
+	}
+}
+
+// parseComplex 解析由组合符连接的选择器
+func (p *selectorParser) parseComplex() (*ComplexSelector, error) {
+	var err error
+	var space bool
+	var char byte
+	var part *CompoundSelector
+	var item = &ComplexSelector{Parts: make([]*CompoundSelector, 0, 3)}
+
+	for {
+		if part, err = p.parseCompound(); nil != err {
+			return nil, err
+		}
+
+		item.Parts = append(item.Parts, part)
+		space = p.skipSpace()
+		char = p.peek()
+		if '>' == char || '+' == char || '~' == char {
+			p.pos++
+			p.skipSpace()
+			item.Combinators = append(item.Combinators, char)
+		} else if 0 == char || ',' == char || ')' == char {
+			return item, nil
+		} else if space {
+			item.Combinators = append(item.Combinators, ' ')
+		} else {
+			return nil, p.error("无法识别的字符 " + string(char))
+		}
+	}
+}
+
+// parseCompound 解析复合选择器
+func (p *selectorParser) parseCompound() (*CompoundSelector, error) {
+	var err error
+	var name string
+	var attr *AttrSelector
+	var pseudo *PseudoSelector
+	var start = p.pos
+	var part = new(CompoundSelector)
+
+	if '*' == p.peek() {
+		p.pos++
+		part.Tag = "*"
+	} else if name = p.parseIdent(); "" != name {
+		part.Tag = strings.ToLower(name)
+	}
+
+	for {
+		switch p.peek() {
+		case '.':
+			p.pos++
+			if name = p.parseIdent(); "" == name {
+				return nil, p.error("类名不能为空")
+			}
+
+			part.Classes = append(part.Classes, name)
+		case '#':
+			p.pos++
+			if name = p.parseIdent(); "" == name {
+				return nil, p.error("ID 不能为空")
+			}
+
+			part.IDs = append(part.IDs, name)
+		case '[':
+			if attr, err = p.parseAttr(); nil != err {
+				return nil, err
+			}
+
+			part.Attrs = append(part.Attrs, attr)
+		case ':':
+			if pseudo, err = p.parsePseudo(); nil != err {
+				return nil, err
+			}
+
+			part.Pseudos = append(part.Pseudos, pseudo)
+		default:
+			if start == p.pos {
+				return nil, p.error("缺少选择器")
+			}
+
+			return part, nil
+		}
+	}
+}
+
+// parseIdent 解析标识符，支持反斜杠转义与非 ASCII 字符
+func (p *selectorParser) parseIdent() string {
+	var char byte
+	var buf = new(strings.Builder)
+
+	for p.pos < len(p.data) {
+		char = p.data[p.pos]
+		if '\\' == char && p.pos+1 < len(p.data) {
+			var r, size = utf8.DecodeRuneInString(p.data[p.pos+1:])
+
+			buf.WriteRune(r)
+			p.pos += size + 1
+		} else if '-' == char || '_' == char || (char >= '0' && char <= '9') || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || char >= 0x80 {
+			buf.WriteByte(char)
+			p.pos++
+		} else {
+			break
+		}
+	}
+
+	return buf.String()
+}
+
+// parseAttr 解析属性选择器，如 [href^="entry://" i]
+func (p *selectorParser) parseAttr() (*AttrSelector, error) {
+	var attr = new(AttrSelector)
+
+	p.pos++
+	p.skipSpace()
+	if attr.Name = strings.ToLower(p.parseIdent()); "" == attr.Name {
+		return nil, p.error("属性名不能为空")
+	}
+
+	p.skipSpace()
+	if ']' == p.peek() {
+		p.pos++
+
+		return attr, nil
+	}
+
+	if '=' == p.peek() {
+		attr.Op = "="
+		p.pos++
+	} else if p.pos+1 < len(p.data) && '=' == p.data[p.pos+1] && strings.IndexByte("~|^$*", p.data[p.pos]) >= 0 {
+		attr.Op = p.data[p.pos : p.pos+2]
+		p.pos += 2
+	} else {
+		return nil, p.error("无法识别的属性比较符")
+	}
+
+	p.skipSpace()
+	if "=" == attr.Op {
+		// 旧版选择器把比较方式写在值的开头，给出新写法提示
+		switch p.peek() {
+		case '^', '$':
+			return nil, p.error("不再支持 [" + attr.Name + "=" + string(p.peek()) + "值] 写法，请改为 [" + attr.Name + string(p.peek()) + "=值]")
+		case '~':
+			return nil, p.error("不再支持 [" + attr.Name + "=~值] 写法，请改为 [" + attr.Name + "*=值]")
+		case '*':
+			return nil, p.error("不再支持 [" + attr.Name + "=*] 写法，请改为 [" + attr.Name + "]")
+		}
+	}
+	if quote := p.peek(); '"' == quote || '\'' == quote {
+		var end = strings.IndexByte(p.data[p.pos+1:], quote)
+		if -1 == end {
+			return nil, p.error("属性值缺少结束引号")
+		}
+
+		attr.Value = p.data[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+	} else if attr.Value = p.parseIdent(); "" == attr.Value {
+		return nil, p.error("属性值不能为空")
+	}
+
+	p.skipSpace()
+	if 'i' == p.peek() || 'I' == p.peek() {
+		attr.Fold = true
+		p.pos++
+		p.skipSpace()
+	} else if 's' == p.peek() || 'S' == p.peek() {
+		p.pos++
+		p.skipSpace()
+	}
+	if ']' != p.peek() {
+		return nil, p.error("属性选择器缺少 ]")
+	}
+
+	p.pos++
+
+	return attr, nil
+}
+
+// parsePseudo 解析伪类选择器
+func (p *selectorParser) parsePseudo() (*PseudoSelector, error) {
+	var err error
+	var end int
+	var pseudo = new(PseudoSelector)
+
+	p.pos++
+	if ':' == p.peek() {
+		return nil, p.error("不支持伪元素")
+	}
+
+	pseudo.Name = strings.ToLower(p.parseIdent())
+	switch pseudo.Name {
+	case "first-child", "last-child", "only-child", "empty":
+		return pseudo, nil
+	case "not":
+		if '(' != p.peek() {
+			return nil, p.error(":not 缺少参数")
+		}
+
+		p.pos++
+		if pseudo.Not, err = p.parseList(); nil != err {
+			return nil, err
+		}
+
+		p.skipSpace()
+		if ')' != p.peek() {
+			return nil, p.error(":not 缺少 )")
+		}
+
+		p.pos++
+
+		return pseudo, nil
+	case "nth-child", "nth-last-child":
+		if '(' != p.peek() {
+			return nil, p.error(":" + pseudo.Name + " 缺少参数")
+		}
+		if end = strings.IndexByte(p.data[p.pos:], ')'); -1 == end {
+			return nil, p.error(":" + pseudo.Name + " 缺少 )")
+		}
+		if pseudo.A, pseudo.B, err = parseNth(p.data[p.pos+1 : p.pos+end]); nil != err {
+			return nil, p.error(err.Error())
+		}
+
+		p.pos += end + 1
+
+		return pseudo, nil
+	default:
+		return nil, p.error("不支持的伪类 :" + pseudo.Name)
+	}
+}
+
+// parseNth 解析 an+b 表达式
+func parseNth(text string) (int, int, error) {
+	var err error
+	var a, b int
+	var pos int
+
+	text = strings.ToLower(strings.ReplaceAll(text, " ", ""))
+	switch text {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+
+	if pos = strings.IndexByte(text, 'n'); -1 == pos {
+		b, err = strconv.Atoi(text)
+
+		return 0, b, err
+	}
+
+	switch text[:pos] {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		if a, err = strconv.Atoi(text[:pos]); nil != err {
+			return 0, 0, errors.New("无法识别的表达式 " + text)
+		}
+	}
+	if pos+1 < len(text) {
+		if b, err = strconv.Atoi(text[pos+1:]); nil != err {
+			return 0, 0, errors.New("无法识别的表达式 " + text)
+		}
+	}
+
+	return a, b, nil
+}
+
+// isElement 是否为元素标签
+func (t *Tag) isElement() bool {
+	return "start" == t.category || "self" == t.category
+}
+
+// hasClass 标签是否包含指定的类名
+func (t *Tag) hasClass(name string) bool {
+	var attr = t.Get("class")
+
+	if nil != attr {
+		for _, v := range strings.Fields(attr.value) {
+			if v == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// index 建立标签编号与子元素索引，DOM 结构变化后需要重新建立
+func (d *Dom) index() {
+	if nil != d.nodes {
+		return
+	}
+
+	d.nodes = make(map[int64]*Tag, len(d.root))
+	d.children = make(map[int64][]*Tag, len(d.root)/2)
+	for _, tag := range d.root {
+		d.nodes[tag.id] = tag
+		if "close" != tag.category {
+			d.children[tag.parent] = append(d.children[tag.parent], tag)
+		}
+	}
+}
+
+// reindex 标记 DOM 结构已变化
+func (d *Dom) reindex() {
+	d.nodes = nil
+	d.children = nil
+}
+
+// Parent 返回标签的上级元素
+func (d *Dom) Parent(tag *Tag) *Tag {
+	d.index()
+	if 0 == tag.parent {
+		return nil
+	}
+
+	return d.nodes[tag.parent]
+}
+
+// siblings 返回与标签同级的元素及标签所在的位置，已删除的元素不计入
+func (d *Dom) siblings(tag *Tag) ([]*Tag, int) {
+	var pos = -1
+	var elements = make([]*Tag, 0, 10)
+
+	d.index()
+	for _, v := range d.children[tag.parent] {
+		if v.state && v.isElement() {
+			if v == tag {
+				pos = len(elements)
+			}
+
+			elements = append(elements, v)
+		}
+	}
+
+	return elements, pos
+}
+
+// Match 标签是否匹配选择器，组合符通过 Tag.parent 记录的上级标签链判断
+func (d *Dom) Match(tag *Tag, selector *TagSelector) bool {
+	if nil == tag || nil == selector || !tag.isElement() {
+		return false
+	}
+
+	for _, item := range selector.Items {
+		if d.matchComplex(tag, item, len(item.Parts)-1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchComplex 从右向左匹配组合选择器
+func (d *Dom) matchComplex(tag *Tag, item *ComplexSelector, idx int) bool {
+	var parent *Tag
+	var siblings []*Tag
+	var pos int
+
+	if !d.matchCompound(tag, item.Parts[idx]) {
+		return false
+	}
+	if 0 == idx {
+		return true
+	}
+
+	switch item.Combinators[idx-1] {
+	case '>':
+		return d.matchComplex(d.Parent(tag), item, idx-1)
+	case ' ':
+		for parent = d.Parent(tag); nil != parent; parent = d.Parent(parent) {
+			if d.matchComplex(parent, item, idx-1) {
+				return true
+			}
+		}
+	case '+':
+		if siblings, pos = d.siblings(tag); pos > 0 {
+			return d.matchComplex(siblings[pos-1], item, idx-1)
+		}
+	case '~':
+		siblings, pos = d.siblings(tag)
+		for pos--; pos >= 0; pos-- {
+			if d.matchComplex(siblings[pos], item, idx-1) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchCompound 匹配复合选择器
+func (d *Dom) matchCompound(tag *Tag, part *CompoundSelector) bool {
+	var attr *TagAttr
+
+	if nil == tag || !tag.isElement() {
+		return false
+	}
+	if "" != part.Tag && "*" != part.Tag && strings.ToLower(tag.name) != part.Tag {
+		return false
+	}
+	for _, v := range part.IDs {
+		if attr = tag.Get("id"); nil == attr || attr.value != v {
+			return false
+		}
+	}
+	for _, v := range part.Classes {
+		if !tag.hasClass(v) {
+			return false
+		}
+	}
+	for _, v := range part.Attrs {
+		if !v.match(tag.Get(v.Name)) {
+			return false
+		}
+	}
+	for _, v := range part.Pseudos {
+		if !d.matchPseudo(tag, v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// match 属性是否匹配属性选择器
+func (a *AttrSelector) match(attr *TagAttr) bool {
+	var val, need string
+
+	if nil == attr {
+		return false
+	}
+	if "" == a.Op {
+		return true
+	}
+
+	val, need = attr.value, a.Value
+	if a.Fold {
+		val, need = strings.ToLower(val), strings.ToLower(need)
+	}
+
+	switch a.Op {
+	case "=":
+		return val == need
+	case "~=":
+		for _, v := range strings.Fields(val) {
+			if v == need {
+				return true
+			}
+		}
+	case "|=":
+		return val == need || strings.HasPrefix(val, need+"-")
+	case "^=":
+		return "" != need && strings.HasPrefix(val, need)
+	case "$=":
+		return "" != need && strings.HasSuffix(val, need)
+	case "*=":
+		return "" != need && strings.Contains(val, need)
+	}
+
+	return false
+}
+
+// matchPseudo 匹配伪类选择器
+func (d *Dom) matchPseudo(tag *Tag, pseudo *PseudoSelector) bool {
+	var pos int
+	var siblings []*Tag
+
+	switch pseudo.Name {
+	case "not":
+		return !d.Match(tag, pseudo.Not)
+	case "empty":
+		if "self" == tag.category {
+			return true
+		}
+
+		d.index()
+		for _, v := range d.children[tag.id] {
+			if v.state && (v.isElement() || ("content" == v.category && "" != strings.Trim(v.value, "\r\n\t "))) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	siblings, pos = d.siblings(tag)
+	switch pseudo.Name {
+	case "first-child":
+		return 0 == pos
+	case "last-child":
+		return pos+1 == len(siblings)
+	case "only-child":
+		return 1 == len(siblings)
+	case "nth-child":
+		return matchNth(pseudo.A, pseudo.B, pos+1)
+	case "nth-last-child":
+		return matchNth(pseudo.A, pseudo.B, len(siblings)-pos)
+	}
+
+	return false
+}
+
+// matchNth 位置是否满足 an+b
+func matchNth(a int, b int, pos int) bool {
+	if 0 == a {
+		return pos == b
+	}
+
+	return 0 == (pos-b)%a && (pos-b)/a >= 0
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// selectorDoc 选择器匹配测试用的词条正文，每个元素用 id 标识
+const selectorDoc = `<div id="d1" class="a b"><p id="p1" class="x" lang="en-US">one</p><p id="p2" title="hello world"><span id="s1" data-v="Abc"></span></p><input id="i1" disabled><input id="i2" type="checkbox" checked/><p id="p3"></p></div><a id="a1" name="top" href="entry://word">a</a>`
+
+func TestParseSelector(t *testing.T) {
+	var cases = []struct {
+		text string
+		err  string
+	}{
+		{"div", ""},
+		{"div.a.b[data-x]", ""},
+		{"div > p + p ~ input, a[href^=entry]", ""},
+		{"[title~=hello i]", ""},
+		{"p:nth-child(2n+1):not(.x)", ""},
+		{"[x='^a']", ""},
+		{"", "缺少选择器"},
+		{"div >", "缺少选择器"},
+		{"div >> p", "缺少选择器"},
+		{"p:hover", "不支持的伪类 :hover"},
+		{"p:nth-of-type(1)", "不支持的伪类 :nth-of-type"},
+		{"[]", "属性名不能为空"},
+		{"[a=\"b]", "属性值缺少结束引号"},
+		{"[a=b", "属性选择器缺少 ]"},
+		{"a[href=^http]", "请改为 [href^=值]"},
+		{"[src=$.png]", "请改为 [src$=值]"},
+		{"[title=~ab]", "请改为 [title*=值]"},
+		{"[x=*]", "请改为 [x]"},
+	}
+
+	for _, v := range cases {
+		var _, err = ParseSelector(v.text)
+		if "" == v.err {
+			if nil != err {
+				t.Errorf("ParseSelector(%q) 返回错误 %v", v.text, err)
+			}
+		} else if nil == err || !strings.Contains(err.Error(), v.err) {
+			t.Errorf("ParseSelector(%q) 的错误为 %v，应包含 %q", v.text, err, v.err)
+		}
+	}
+}
+
+func TestDomMatch(t *testing.T) {
+	var cases = []struct {
+		selector string
+		want     string
+	}{
+		{"p", "p1,p2,p3"},
+		{"#p2", "p2"},
+		{"#top", ""},
+		{"[name=top]", "a1"},
+		{".a.b", "d1"},
+		{".a.c", ""},
+		{"div > p", "p1,p2,p3"},
+		{"div span", "s1"},
+		{"div > span", ""},
+		{"p + p", "p2"},
+		{"p ~ p", "p2,p3"},
+		{"p ~ input", "i1,i2"},
+		{"[disabled]", "i1"},
+		{"input[checked]", "i2"},
+		{"input:not([checked])", "i1"},
+		{"[lang|=en]", "p1"},
+		{"[title~=world]", "p2"},
+		{"[title~=wor]", ""},
+		{"[href^=entry]", "a1"},
+		{"[href$=word]", "a1"},
+		{"[href*=\"://\"]", "a1"},
+		{"[data-v=abc]", ""},
+		{"[data-v=abc i]", "s1"},
+		{"div > :first-child", "p1"},
+		{"div > :last-child", "p3"},
+		{"p:only-child", ""},
+		{"span:only-child", "s1"},
+		{"div > :nth-child(2n+1)", "p1,i1,p3"},
+		{"div > :nth-last-child(1)", "p3"},
+		{"p:empty", "p3"},
+		{"input:empty", "i1,i2"},
+		{"p.x, a", "p1,a1"},
+	}
+
+	var dom = parseBodyItem(&Entry{word: "test"}, selectorDoc)
+	for _, v := range cases {
+		var sel = MustParseSelector(v.selector)
+		var found = make([]string, 0, 5)
+
+		for _, tag := range dom.root {
+			if tag.isElement() && dom.Match(tag, sel) {
+				found = append(found, tag.Get("id").value)
+			}
+		}
+		if got := strings.Join(found, ","); got != v.want {
+			t.Errorf("选择器 %q 匹配到 %q，应为 %q", v.selector, got, v.want)
+		}
+	}
+}
+
+func TestDomMatchDropped(t *testing.T) {
+	var cases = []struct {
+		selector string
+		want     string
+	}{
+		{"div > :first-child", "p2"},
+		{"div > :last-child", "p2"},
+		{"p:only-child", "p2"},
+		{"p + p", ""},
+		{"div > :nth-child(1)", "p2"},
+		{"div:empty", ""},
+		{"p:empty", "p2"},
+	}
+
+	// 删除 p1、p3 与 p2 中的 span 后，p2 是 div 唯一的子元素，本身也没有内容
+	var dom = parseBodyItem(&Entry{word: "test"}, `<div id="d1"><p id="p1">one</p><p id="p2"><span id="s1">x</span></p><p id="p3"></p></div>`)
+	for _, sel := range []string{"#p1", "#p3", "#s1"} {
+		(&TidyRule{Action: "Drop", sel: MustParseSelector(sel)}).Apply(dom, &Entry{word: "test"})
+	}
+
+	for _, v := range cases {
+		var sel = MustParseSelector(v.selector)
+		var found = make([]string, 0, 5)
+
+		for _, tag := range dom.root {
+			if tag.state && tag.isElement() && dom.Match(tag, sel) {
+				found = append(found, tag.Get("id").value)
+			}
+		}
+		if got := strings.Join(found, ","); got != v.want {
+			t.Errorf("选择器 %q 匹配到 %q，应为 %q", v.selector, got, v.want)
+		}
+	}
+}
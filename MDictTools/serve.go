@@ -0,0 +1,364 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ServeOption 词典预览服务选项
+type ServeOption struct {
+	Limit    int    `label:"前缀搜索返回的词头数"`
+	Listen   string `label:"监听地址"`
+	Input    string `label:"词典源文件"`
+	Original string `label:"对照的原始词典源文件"`
+	CSS      string `label:"词典样式文件"`
+	Resource string `label:"资源文件目录"`
+}
+
+// serveLinkHops 词条页自动跟随的最多链接级数
+const serveLinkHops = 5
+
+// dictItem 词条在源文件中的位置
+type dictItem struct {
+	offset int64 `label:"文件偏移"`
+	size   int   `label:"词条字节数"`
+}
+
+// dictIndex 词典源文件词头索引，词条内容按需从文件读取
+type dictIndex struct {
+	fp    *os.File              `label:"文件句柄"`
+	words []string              `label:"按小写排序的词头"`
+	lower []string              `label:"排序后词头的小写形式"`
+	items map[string][]dictItem `label:"词头对应的词条位置"`
+	fold  map[string]string     `label:"小写词头对应的第一个词头"`
+}
+
+// Init 检查预览服务选项
+func (o *ServeOption) Init() error {
+	var err error
+	var msg = make([]string, 0, 2)
+
+	if "" == o.Input {
+		msg = append(msg, "输入文件属性 Input 不能为空")
+	} else if _, err = os.Stat(o.Input); nil != err {
+		msg = append(msg, "输入文件 "+o.Input+" 不存在")
+	}
+	if "" != o.Original {
+		if _, err = os.Stat(o.Original); nil != err {
+			msg = append(msg, "原始词典源文件 "+o.Original+" 不存在")
+		}
+	}
+	if "" != o.CSS {
+		if _, err = os.Stat(o.CSS); nil != err {
+			msg = append(msg, "样式文件 "+o.CSS+" 不存在")
+		}
+	}
+	if "" != o.Resource {
+		if info, err := os.Stat(o.Resource); nil != err || !info.IsDir() {
+			msg = append(msg, "资源文件目录 "+o.Resource+" 不存在")
+		}
+	}
+	if "" == o.Listen {
+		o.Listen = "127.0.0.1:8080"
+	}
+	if o.Limit < 1 {
+		o.Limit = 100
+	}
+
+	if len(msg) > 0 {
+		err = errors.New(strings.Join(msg, "\n"))
+	}
+
+	return err
+}
+
+// openDictIndex 读取词典源文件建立词头索引
+func openDictIndex(file string) (*dictIndex, error) {
+	var err error
+	var offset int64
+	var chunk []byte
+	var element *Entry
+	var reader *EntryReader
+	var idx = &dictIndex{items: make(map[string][]dictItem, 100000), fold: make(map[string]string, 100000)}
+
+	if reader, err = OpenEntryReader(file); nil != err {
+		return nil, err
+	}
+	defer reader.Close()
+
+	for {
+		if chunk, offset, err = reader.Next(); nil != err {
+			break
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element {
+			continue
+		}
+
+		if _, ok := idx.items[element.word]; !ok {
+			idx.words = append(idx.words, element.word)
+		}
+		if _, ok := idx.fold[strings.ToLower(element.word)]; !ok {
+			idx.fold[strings.ToLower(element.word)] = element.word
+		}
+		idx.items[element.word] = append(idx.items[element.word], dictItem{offset: offset, size: len(chunk)})
+	}
+	if io.EOF != err {
+		return nil, err
+	}
+
+	sort.Slice(idx.words, func(i int, j int) bool {
+		return strings.ToLower(idx.words[i]) < strings.ToLower(idx.words[j])
+	})
+	idx.lower = make([]string, len(idx.words))
+	for k, v := range idx.words {
+		idx.lower[k] = strings.ToLower(v)
+	}
+
+	if idx.fp, err = os.Open(file); nil != err {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+// Close 关闭词典源文件
+func (x *dictIndex) Close() {
+	if nil != x.fp {
+		_ = x.fp.Close()
+		x.fp = nil
+	}
+}
+
+// resolve 返回词头在索引中的写法，与 MDict 一样不区分大小写，完全相同的词头优先
+func (x *dictIndex) resolve(word string) string {
+	if _, ok := x.items[word]; ok {
+		return word
+	}
+	if v, ok := x.fold[strings.ToLower(word)]; ok {
+		return v
+	}
+
+	return word
+}
+
+// Search 返回以 prefix 开头的词头，忽略大小写
+func (x *dictIndex) Search(prefix string, limit int) []string {
+	var ret = make([]string, 0, limit)
+
+	prefix = strings.ToLower(prefix)
+	for k := sort.SearchStrings(x.lower, prefix); k < len(x.lower) && len(ret) < limit; k++ {
+		if !strings.HasPrefix(x.lower[k], prefix) {
+			break
+		}
+
+		ret = append(ret, x.words[k])
+	}
+
+	return ret
+}
+
+// Get 返回词头的全部正文，不含词头行，忽略大小写
+func (x *dictIndex) Get(word string) []string {
+	var err error
+	var chunk []byte
+	var ret = make([]string, 0, 1)
+
+	for _, v := range x.items[x.resolve(word)] {
+		chunk = make([]byte, v.size)
+		if _, err = x.fp.ReadAt(chunk, v.offset); nil != err {
+			continue
+		}
+		if pos := strings.IndexAny(string(chunk), "\r\n"); -1 != pos {
+			ret = append(ret, strings.Trim(string(chunk[pos:]), "\r\n\t "))
+		}
+	}
+
+	return ret
+}
+
+// Link 返回词头为链接时的目标词头
+func (x *dictIndex) Link(word string) string {
+	var bodies = x.Get(word)
+
+	if 1 == len(bodies) && strings.HasPrefix(bodies[0], "@@@LINK=") {
+		return strings.TrimSpace(bodies[0][8:])
+	}
+
+	return ""
+}
+
+// Follow 沿链接返回最终指向的词头，词头不是链接时返回自身，出现循环或超过 serveLinkHops 级时返回空
+func (x *dictIndex) Follow(word string) string {
+	var target = x.Link(word)
+	var seen = map[string]bool{x.resolve(word): true}
+
+	for hops := 1; "" != target; hops++ {
+		if hops > serveLinkHops || seen[x.resolve(target)] {
+			return ""
+		}
+
+		seen[x.resolve(target)] = true
+		word, target = target, x.Link(target)
+	}
+
+	return word
+}
+
+// resourceRegex 正文中相对路径的资源地址
+var resourceRegex = regexp.MustCompile(`(?i)\b(src|href)=(["'])([^"'/#:][^"':]*)["']`)
+
+// rewriteBody 把 MDict 专用的链接与相对路径的资源改为预览服务的地址
+func rewriteBody(body string) string {
+	body = strings.ReplaceAll(body, "entry://#", "#")
+	body = strings.ReplaceAll(body, "entry://", "/entry/")
+	body = strings.ReplaceAll(body, "sound://", "/res/")
+
+	return resourceRegex.ReplaceAllString(body, "$1=$2/res/$3$2")
+}
+
+// serveMdict 启动词典预览服务
+//
+// 实现思路：
+//
+//	1、为词典源文件与对照的原始源文件建立词头索引，只保存词条在文件中的位置
+//	2、首页按前缀搜索词头，词条页用词典样式显示正文，设置了原始源文件时左右对照显示
+//	3、entry:// 链接改为词条页地址，sound:// 与相对路径的资源从资源目录读取
+func serveMdict(cfg string) error {
+	var err error
+	var css string
+	var origin, input *dictIndex
+	var opt = new(ServeOption)
+	var mux = http.NewServeMux()
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if input, err = openDictIndex(opt.Input); nil != err {
+		return err
+	}
+	defer input.Close()
+
+	if "" != opt.Original {
+		if origin, err = openDictIndex(opt.Original); nil != err {
+			return err
+		}
+		defer origin.Close()
+	}
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		var q = r.URL.Query().Get("q")
+		var buf = new(strings.Builder)
+
+		if "/" != r.URL.Path {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		writeServeHeader(buf, q, css)
+		if "" != q {
+			for _, v := range input.Search(q, opt.Limit) {
+				buf.WriteString(`<a class="word" href="/entry/` + url.PathEscape(v) + `">` + html.EscapeString(v) + "</a>")
+			}
+		}
+		buf.WriteString("</body></html>")
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, buf.String())
+	})
+
+	mux.HandleFunc("/entry/", func(w http.ResponseWriter, r *http.Request) {
+		var word = strings.TrimPrefix(r.URL.Path, "/entry/")
+		var buf = new(strings.Builder)
+
+		// 直接跳转到最终指向的词条，循环或多级的链接显示链接词条本身
+		if target := input.Follow(word); "" != target && target != word {
+			http.Redirect(w, r, "/entry/"+url.PathEscape(target), http.StatusFound)
+
+			return
+		}
+
+		writeServeHeader(buf, word, css)
+		if nil == origin {
+			writeServeEntry(buf, "", input.Get(word))
+		} else {
+			buf.WriteString(`<table class="compare"><tr><td>`)
+			writeServeEntry(buf, opt.Original, origin.Get(word))
+			buf.WriteString("</td><td>")
+			writeServeEntry(buf, opt.Input, input.Get(word))
+			buf.WriteString("</td></tr></table>")
+		}
+		buf.WriteString("</body></html>")
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, buf.String())
+	})
+
+	// 样式文件放在资源目录下，样式中相对路径的图片与字体同样从资源目录读取
+	if "" != opt.CSS {
+		css = "/res/" + filepath.Base(opt.CSS)
+		mux.HandleFunc(css, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/css; charset=utf-8")
+			http.ServeFile(w, r, opt.CSS)
+		})
+	}
+	if "" != opt.Resource {
+		mux.Handle("/res/", http.StripPrefix("/res/", http.FileServer(http.Dir(opt.Resource))))
+	}
+
+	fmt.Println("serve", opt.Input, "entries:", len(input.words), "at http://"+opt.Listen+"/")
+
+	return http.ListenAndServe(opt.Listen, mux)
+}
+
+// writeServeHeader 输出页面头部与搜索框
+func writeServeHeader(buf *strings.Builder, q string, css string) {
+	buf.WriteString(`<!DOCTYPE html><html><head><meta charset="utf-8"><title>` + html.EscapeString(q) + `</title>`)
+	if "" != css {
+		buf.WriteString(`<link rel="stylesheet" href="` + html.EscapeString(css) + `">`)
+	}
+	buf.WriteString(`<style>
+.mdt-bar{padding:8px;border-bottom:1px solid #ccc;margin-bottom:8px}
+a.word{display:block;padding:2px 8px}
+table.compare{width:100%;table-layout:fixed;border-collapse:collapse}
+table.compare td{vertical-align:top;border:1px solid #ddd;padding:4px}
+.mdt-title{color:#888;font-size:12px}
+</style></head><body><form class="mdt-bar" action="/" method="get"><input name="q" value="`)
+	buf.WriteString(html.EscapeString(q) + `" autofocus> <button>搜索</button></form>`)
+}
+
+// writeServeEntry 输出词条正文，同一词头有多个词条时依次显示
+func writeServeEntry(buf *strings.Builder, title string, bodies []string) {
+	if "" != title {
+		buf.WriteString(`<div class="mdt-title">` + html.EscapeString(title) + "</div>")
+	}
+	if 0 == len(bodies) {
+		buf.WriteString("<p>没有找到词条</p>")
+	}
+	for k, v := range bodies {
+		if k > 0 {
+			buf.WriteString("<hr>")
+		}
+		if strings.HasPrefix(v, "@@@LINK=") {
+			v = strings.TrimSpace(v[8:])
+			buf.WriteString(`<p>@@@LINK=<a href="/entry/` + url.PathEscape(v) + `">` + html.EscapeString(v) + "</a></p>")
+
+			continue
+		}
+
+		buf.WriteString(rewriteBody(v))
+	}
+}
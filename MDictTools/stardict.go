@@ -0,0 +1,654 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dictzipChunk dictzip 每个压缩块的原始数据长度，与 dictzip 工具的默认值一致
+const dictzipChunk = 58315
+
+// StarDictOption StarDict 词典导出选项
+type StarDictOption struct {
+	Input       string `label:"词典源文件"`
+	Output      string `label:"输出的 .ifo 文件"`
+	Title       string `label:"词典标题"`
+	Author      string `label:"词典作者"`
+	Description string `label:"词典描述"`
+	CSS         string `label:"词典样式文件"`
+	Resource    string `label:"资源目录或 mdd 资源文件"`
+}
+
+// starDictWord StarDict 索引中的一个词条
+type starDictWord struct {
+	word   string `label:"词头"`
+	offset int64  `label:"数据偏移"`
+	size   int    `label:"数据长度"`
+}
+
+// dictzipWriter 按 dictzip 格式分块压缩词典数据，每块单独压缩以便随机读取
+type dictzipWriter struct {
+	total   int64         `label:"原始数据总长度"`
+	sizes   []int         `label:"每块压缩后的长度"`
+	pending []byte        `label:"未压缩的数据"`
+	out     *bytes.Buffer `label:"压缩后的数据"`
+	crc     hash.Hash32   `label:"原始数据校验和"`
+}
+
+// Init 检查导出选项
+func (o *StarDictOption) Init() error {
+	var pos int
+	var err error
+	var msg = make([]string, 0, 2)
+
+	if "" == o.Input {
+		return errors.New("输入文件属性 Input 不能为空")
+	}
+	if _, err = os.Stat(o.Input); nil != err {
+		return errors.New("输入文件 " + o.Input + " 不存在")
+	}
+
+	pos = len(o.Input) - len(filepath.Ext(o.Input))
+	if "" == o.Output {
+		o.Output = o.Input[:pos] + ".ifo"
+	} else if ".ifo" != strings.ToLower(filepath.Ext(o.Output)) {
+		msg = append(msg, "输出文件 Output 的扩展名必须是 .ifo")
+	}
+	if "" == o.Title {
+		o.Title = filepath.Base(o.Input[:pos])
+	}
+	if "" != o.CSS {
+		if _, err = os.Stat(o.CSS); nil != err {
+			msg = append(msg, "样式文件 "+o.CSS+" 不存在")
+		}
+	}
+	if "" != o.Resource {
+		if _, err = os.Stat(o.Resource); nil != err {
+			msg = append(msg, "资源 "+o.Resource+" 不存在")
+		}
+	}
+
+	if len(msg) > 0 {
+		err = errors.New(strings.Join(msg, "\n"))
+	}
+
+	return err
+}
+
+// newDictzipWriter 创建 dictzip 压缩器
+func newDictzipWriter() *dictzipWriter {
+	return &dictzipWriter{
+		sizes:   make([]int, 0, 100),
+		pending: make([]byte, 0, dictzipChunk*2),
+		out:     new(bytes.Buffer),
+		crc:     crc32.NewIEEE(),
+	}
+}
+
+// Write 写入原始数据，满一块时压缩
+func (w *dictzipWriter) Write(data []byte) (int, error) {
+	var err error
+
+	w.total += int64(len(data))
+	w.pending = append(w.pending, data...)
+	_, _ = w.crc.Write(data)
+
+	for len(w.pending) > dictzipChunk {
+		if err = w.compress(w.pending[:dictzipChunk], false); nil != err {
+			return 0, err
+		}
+
+		w.pending = append(w.pending[:0], w.pending[dictzipChunk:]...)
+	}
+
+	return len(data), nil
+}
+
+// compress 压缩一块数据，每块使用新的压缩器，最后一块结束压缩流
+func (w *dictzipWriter) compress(data []byte, last bool) error {
+	var err error
+	var fw *flate.Writer
+	var size = w.out.Len()
+
+	if fw, err = flate.NewWriter(w.out, flate.BestCompression); nil != err {
+		return err
+	}
+	if _, err = fw.Write(data); nil != err {
+		return err
+	}
+	if last {
+		err = fw.Close()
+	} else {
+		err = fw.Flush()
+	}
+
+	w.sizes = append(w.sizes, w.out.Len()-size)
+
+	return err
+}
+
+// Save 压缩剩余数据，写入带有 RA 分块表的 gzip 文件
+func (w *dictzipWriter) Save(file string) error {
+	var err error
+	var extra []byte
+	var num16 [2]byte
+	var num32 [4]byte
+	var header = new(bytes.Buffer)
+
+	if err = w.compress(w.pending, true); nil != err {
+		return err
+	}
+	if 10+4*len(w.sizes) > 0xffff {
+		return errors.New("词典数据过大，超出 dictzip 分块表的容量")
+	}
+
+	// RA 子字段：版本、块长度、块数、每块压缩后的长度
+	extra = append(extra, 'R', 'A', 0, 0)
+	for _, v := range append([]int{1, dictzipChunk, len(w.sizes)}, w.sizes...) {
+		binary.LittleEndian.PutUint16(num16[:], uint16(v))
+		extra = append(extra, num16[:]...)
+	}
+	binary.LittleEndian.PutUint16(extra[2:], uint16(len(extra)-4))
+
+	header.Write([]byte{0x1f, 0x8b, 8, 4})
+	binary.LittleEndian.PutUint32(num32[:], uint32(time.Now().Unix()))
+	header.Write(num32[:])
+	header.Write([]byte{2, 3})
+	binary.LittleEndian.PutUint16(num16[:], uint16(len(extra)))
+	header.Write(num16[:])
+	header.Write(extra)
+
+	header.Write(w.out.Bytes())
+	binary.LittleEndian.PutUint32(num32[:], w.crc.Sum32())
+	header.Write(num32[:])
+	binary.LittleEndian.PutUint32(num32[:], uint32(w.total))
+	header.Write(num32[:])
+
+	return os.WriteFile(file, header.Bytes(), os.ModePerm)
+}
+
+// starDictLess StarDict 词头排序规则：先按 ASCII 忽略大小写比较，相同时按字节比较
+func starDictLess(a string, b string) bool {
+	var ca, cb byte
+
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if ca, cb = asciiLower(a[k]), asciiLower(b[k]); ca != cb {
+			return ca < cb
+		}
+	}
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+
+	return a < b
+}
+
+// asciiLower 把 ASCII 大写字母转换为小写
+func asciiLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+
+	return c
+}
+
+// starDictBody 把 MDict 专用的词条链接改为 StarDict 阅读器支持的 bword:// 链接
+func starDictBody(body string) string {
+	body = strings.ReplaceAll(body, "entry://#", "#")
+
+	return strings.ReplaceAll(body, "entry://", "bword://")
+}
+
+// exportStarDict 将词典源文件导出为 StarDict 词典
+//
+// 实现思路：
+//
+//	1、第一遍读取词头与链接，沿链接找到最终指向的词条，去除无效的链接
+//	2、第二遍按源文件顺序把正文写入 .dict.dz，记录每个词条的偏移与长度
+//	3、词条按 StarDict 规则排序后写入 .idx，有效的链接写入 .syn 并指向目标词条在 .idx 中的序号
+//	4、正文按 h 类型保存，写入 .ifo 信息文件，样式与资源复制到 .ifo 所在的目录
+func exportStarDict(cfg string) error {
+	var err error
+	var base, body string
+	var chunk []byte
+	var num32 [4]byte
+	var element *Entry
+	var reader *EntryReader
+	var report *LinkReport
+	var words map[string]bool
+	var links map[string]string
+	var dz = newDictzipWriter()
+	var opt = new(StarDictOption)
+	var idx = new(bytes.Buffer)
+	var syn = new(bytes.Buffer)
+	var ifo = new(strings.Builder)
+	var first = make(map[string]int, 100000)
+	var list = make([]*starDictWord, 0, 100000)
+	var synonyms = make([]string, 0, 100)
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if words, links, err = scanWordLinks(opt.Input, func(chunk []byte, offset int64) *Entry {
+		return parseBody(chunk, 0, len(chunk))
+	}); nil != err {
+		return err
+	}
+
+	report = analyzeLinks(words, links)
+	report.Print()
+
+	if reader, err = OpenEntryReader(opt.Input); nil != err {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		if chunk, _, err = reader.Next(); nil != err {
+			break
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element || "" != element.action || "" == element.word {
+			continue
+		}
+
+		body = ""
+		if pos := bytes.IndexAny(chunk, "\r\n"); -1 != pos {
+			body = starDictBody(strings.Trim(string(chunk[pos:]), "\r\n\t "))
+		}
+
+		list = append(list, &starDictWord{word: element.word, offset: dz.total, size: len(body)})
+		if _, err = dz.Write([]byte(body)); nil != err {
+			return err
+		}
+	}
+	if io.EOF != err {
+		return err
+	}
+
+	sort.SliceStable(list, func(i int, j int) bool {
+		return starDictLess(list[i].word, list[j].word)
+	})
+	for k, v := range list {
+		if _, ok := first[v.word]; !ok {
+			first[v.word] = k
+		}
+		if dz.total > 0xffffffff {
+			return errors.New("词典数据超过 4GB，StarDict 索引无法保存")
+		}
+
+		idx.WriteString(v.word)
+		idx.WriteByte(0)
+		binary.BigEndian.PutUint32(num32[:], uint32(v.offset))
+		idx.Write(num32[:])
+		binary.BigEndian.PutUint32(num32[:], uint32(v.size))
+		idx.Write(num32[:])
+	}
+
+	for word := range report.final {
+		if _, ok := first[word]; !ok {
+			synonyms = append(synonyms, word)
+		}
+	}
+	sort.Slice(synonyms, func(i int, j int) bool {
+		return starDictLess(synonyms[i], synonyms[j])
+	})
+	for _, word := range synonyms {
+		syn.WriteString(word)
+		syn.WriteByte(0)
+		binary.BigEndian.PutUint32(num32[:], uint32(first[report.final[word]]))
+		syn.Write(num32[:])
+	}
+
+	base = opt.Output[:len(opt.Output)-4]
+	if err = os.MkdirAll(filepath.Dir(opt.Output), os.ModePerm); nil != err {
+		return err
+	}
+	if err = dz.Save(base + ".dict.dz"); nil != err {
+		return err
+	}
+	if err = os.WriteFile(base+".idx", idx.Bytes(), os.ModePerm); nil != err {
+		return err
+	}
+	if len(synonyms) > 0 {
+		if err = os.WriteFile(base+".syn", syn.Bytes(), os.ModePerm); nil != err {
+			return err
+		}
+	}
+
+	ifo.WriteString("StarDict's dict ifo file\nversion=3.0.0\n")
+	ifo.WriteString("bookname=" + starDictField(opt.Title) + "\n")
+	ifo.WriteString("wordcount=" + strconv.Itoa(len(list)) + "\n")
+	if len(synonyms) > 0 {
+		ifo.WriteString("synwordcount=" + strconv.Itoa(len(synonyms)) + "\n")
+	}
+	ifo.WriteString("idxfilesize=" + strconv.Itoa(idx.Len()) + "\n")
+	if "" != opt.Author {
+		ifo.WriteString("author=" + starDictField(opt.Author) + "\n")
+	}
+	if "" != opt.Description {
+		ifo.WriteString("description=" + starDictField(opt.Description) + "\n")
+	}
+	ifo.WriteString("date=" + time.Now().Format("2006.01.02") + "\n")
+	ifo.WriteString("sametypesequence=h\n")
+	if err = os.WriteFile(opt.Output, []byte(ifo.String()), os.ModePerm); nil != err {
+		return err
+	}
+
+	fmt.Println("write dict, entries:", len(list), "synonyms:", len(synonyms))
+
+	if "" != opt.CSS {
+		if err = copyFile(opt.CSS, base+".css"); nil != err {
+			return err
+		}
+	}
+	if "" != opt.Resource {
+		return exportResource(opt.Resource, filepath.Join(filepath.Dir(opt.Output), "res"))
+	}
+
+	return nil
+}
+
+// starDictField .ifo 中的值不能包含换行，换行替换为 <br>
+func starDictField(value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "<br>")
+
+	return strings.ReplaceAll(strings.ReplaceAll(value, "\n", "<br>"), "\r", "")
+}
+
+// exportResource 把资源目录或 mdd 资源文件中的文件复制到 res 目录
+func exportResource(src string, dir string) error {
+	var mdd *MDict
+	var info, err = os.Stat(src)
+
+	if nil != err {
+		return err
+	}
+
+	if !info.IsDir() {
+		if mdd, err = OpenMDict(src); nil != err {
+			return err
+		}
+		defer mdd.Close()
+
+		return mdd.Walk(func(key *MDictKey, record []byte) error {
+			var file, err = resourcePath(dir, key.word)
+			if nil != err {
+				return err
+			}
+			if err = os.MkdirAll(filepath.Dir(file), os.ModePerm); nil != err {
+				return err
+			}
+
+			return os.WriteFile(file, record, os.ModePerm)
+		})
+	}
+
+	return filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if nil != err || info.IsDir() {
+			return err
+		}
+
+		var rel string
+		if rel, err = filepath.Rel(src, file); nil != err {
+			return err
+		}
+		if err = os.MkdirAll(filepath.Dir(filepath.Join(dir, rel)), os.ModePerm); nil != err {
+			return err
+		}
+
+		return copyFile(file, filepath.Join(dir, rel))
+	})
+}
+
+// copyFile 复制文件
+func copyFile(src string, dst string) error {
+	var err error
+	var in, out *os.File
+
+	if in, err = os.Open(src); nil != err {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	if out, err = os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return err
+	}
+
+	var buf = bufio.NewWriter(out)
+	if _, err = io.Copy(buf, in); nil == err {
+		err = buf.Flush()
+	}
+	if cerr := out.Close(); nil == err {
+		err = cerr
+	}
+
+	return err
+}
+
+// readStarDictFile 读取 StarDict 词典文件，不存在时读取 gzip 压缩的同名文件
+func readStarDictFile(file string, gz string) ([]byte, error) {
+	var err error
+	var fp *os.File
+	var reader *gzip.Reader
+
+	if _, err = os.Stat(file); nil == err {
+		return os.ReadFile(file)
+	}
+	if fp, err = os.Open(file + gz); nil != err {
+		return nil, err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	if reader, err = gzip.NewReader(fp); nil != err {
+		return nil, err
+	}
+
+	return io.ReadAll(reader)
+}
+
+// starDictRecord 按字段类型把 StarDict 记录转换为 HTML 正文，types 为空时每个字段自带类型
+func starDictRecord(data []byte, types string, separator string) string {
+	var t byte
+	var end int
+	var field string
+	var fields = make([]string, 0, 2)
+
+	for k := 0; len(data) > 0 && ("" == types || k < len(types)); k++ {
+		if "" == types {
+			t, data = data[0], data[1:]
+		} else {
+			t = types[k]
+		}
+
+		switch {
+		case "" != types && k == len(types)-1:
+			end = len(data)
+		case t >= 'a' && t <= 'z':
+			if end = bytes.IndexByte(data, 0); -1 == end {
+				end = len(data)
+			}
+		case len(data) >= 4:
+			end = int(binary.BigEndian.Uint32(data))
+			data = data[4:]
+		default:
+			end = len(data)
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+
+		field, data = string(data[:end]), data[end:]
+		if "" == types || k < len(types)-1 {
+			if t >= 'a' && t <= 'z' && len(data) > 0 {
+				data = data[1:]
+			}
+		}
+
+		switch t {
+		case 'h':
+			fields = append(fields, strings.ReplaceAll(field, "bword://", "entry://"))
+		case 'g', 'x':
+			fields = append(fields, field)
+		case 'r':
+			fields = append(fields, starDictResource(field))
+		default:
+			if t >= 'a' && t <= 'z' {
+				fields = append(fields, strings.ReplaceAll(html.EscapeString(strings.Trim(field, "\r\n")), "\n", "<br/>"))
+			}
+		}
+	}
+
+	return strings.Join(fields, separator)
+}
+
+// starDictResource 把 r 类型字段中的资源列表转换为图片与 sound:// 链接
+func starDictResource(field string) string {
+	var pos int
+	var file string
+	var buf = new(strings.Builder)
+
+	for _, line := range strings.Split(field, "\n") {
+		if pos = strings.IndexByte(line, ':'); -1 == pos {
+			continue
+		}
+
+		file = html.EscapeString(strings.TrimSpace(line[pos+1:]))
+		if "img" == line[:pos] {
+			buf.WriteString(`<img src="` + file + `"/>`)
+		} else {
+			buf.WriteString(`<a href="sound://` + file + `">&#9654;</a>`)
+		}
+	}
+
+	return buf.String()
+}
+
+// importStarDict 将 StarDict 词典导入为词典源文件
+//
+// 实现思路：
+//
+//	1、读取 .ifo 取得字段类型与索引偏移位数，.idx 与 .dict 不存在时读取 gzip 或 dictzip 压缩的文件
+//	2、按 .idx 顺序读取记录，h 类型保留 HTML 并把 bword:// 改为 entry://，文本类型转义后换行改为 <br/>
+//	3、.syn 中的同义词生成指向目标词条的 @@@LINK，res 目录可以用 mdd-pack 打包为资源文件
+func importStarDict(cfg string) error {
+	var err error
+	var pos, bits int
+	var base, types string
+	var size, offset uint64
+	var ifo, idx, dict, syn []byte
+	var out *sourceWriter
+	var words = make([]string, 0, 100000)
+	var opt = new(ImportOption)
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(".ifo"); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if ifo, err = os.ReadFile(opt.Input); nil != err {
+		return err
+	}
+	if !bytes.HasPrefix(ifo, []byte("StarDict's dict ifo file")) {
+		return errors.New("文件 " + opt.Input + " 不是 StarDict 词典信息文件")
+	}
+
+	bits = 32
+	for _, line := range strings.Split(string(ifo), "\n") {
+		if pos = strings.IndexByte(line, '='); -1 == pos {
+			continue
+		}
+
+		switch strings.TrimSpace(line[:pos]) {
+		case "bookname":
+			fmt.Println("dict:", strings.TrimSpace(line[pos+1:]))
+		case "sametypesequence":
+			types = strings.TrimSpace(line[pos+1:])
+		case "idxoffsetbits":
+			if "64" == strings.TrimSpace(line[pos+1:]) {
+				bits = 64
+			}
+		}
+	}
+
+	base = opt.Input[:len(opt.Input)-4]
+	if idx, err = readStarDictFile(base+".idx", ".gz"); nil != err {
+		return err
+	}
+	if dict, err = readStarDictFile(base+".dict", ".dz"); nil != err {
+		return err
+	}
+	if out, err = createSource(opt.Output); nil != err {
+		return err
+	}
+
+	for len(idx) > 0 && nil == err {
+		if pos = bytes.IndexByte(idx, 0); -1 == pos || len(idx) < pos+1+bits/8+4 {
+			err = errors.New("索引文件 " + base + ".idx 格式错误")
+
+			break
+		}
+
+		if 64 == bits {
+			offset = binary.BigEndian.Uint64(idx[pos+1:])
+		} else {
+			offset = uint64(binary.BigEndian.Uint32(idx[pos+1:]))
+		}
+		size = uint64(binary.BigEndian.Uint32(idx[pos+1+bits/8:]))
+		if offset+size > uint64(len(dict)) {
+			err = errors.New("词条 " + string(idx[:pos]) + " 超出数据文件范围")
+
+			break
+		}
+
+		words = append(words, string(idx[:pos]))
+		err = out.Add(string(idx[:pos]), starDictRecord(dict[offset:offset+size], types, opt.Separator))
+		idx = idx[pos+1+bits/8+4:]
+	}
+
+	if syn, _ = os.ReadFile(base + ".syn"); nil == err {
+		for len(syn) > 0 && nil == err {
+			if pos = bytes.IndexByte(syn, 0); -1 == pos || len(syn) < pos+5 {
+				break
+			}
+			if k := int(binary.BigEndian.Uint32(syn[pos+1:])); k < len(words) {
+				err = out.Add(string(syn[:pos]), "@@@LINK="+words[k])
+			}
+
+			syn = syn[pos+5:]
+		}
+	}
+
+	if cerr := out.Close(); nil == err {
+		err = cerr
+	}
+	if nil == err {
+		fmt.Println("import stardict, entries:", len(words), "synonyms:", out.num-len(words))
+	}
+
+	return err
+}
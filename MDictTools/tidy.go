@@ -1,17 +1,22 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"html"
+	"io"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -23,6 +28,7 @@ var showHelp = flag.Bool("h", false, "显示应用帮助信息并退出")
 type Entry struct {
 	start  int    `label:"开始坐标"`
 	end    int    `label:"结束坐标"`
+	offset int64  `label:"文件偏移"`
 	word   string `label:"词头"`
 	action string `label:"@@@动作名"`
 	value  string `label:"@@@动作内容"`
@@ -45,6 +51,7 @@ type Tag struct {
 	id       int64      `label:"标签ID"`
 	close    int64      `label:"结束标签ID"`
 	parent   int64      `label:"上级标签ID"`
+	pos      int        `label:"解析时在正文中的字节位置"`
 	category string     `label:"标签分类"`
 	name     string     `label:"标签名"`
 	value    string     `label:"标签内容"`
@@ -62,10 +69,12 @@ func (t *Tag) String() string {
 			if attr.state {
 				buf.WriteString(" ")
 				buf.WriteString(attr.originalName)
-				buf.WriteString("=")
-				buf.WriteString(attr.quote)
-				buf.WriteString(attr.value)
-				buf.WriteString(attr.quote)
+				if "" != attr.quote {
+					buf.WriteString("=")
+					buf.WriteString(attr.quote)
+					buf.WriteString(attr.value)
+					buf.WriteString(attr.quote)
+				}
 			}
 		}
 
@@ -91,76 +100,77 @@ func (t *Tag) Drop() {
 }
 
 // Parse 解析标签属性
+//
+// 实现思路：
+//
+//	1、跳过标签名后依次读取属性名，属性名以空白、=、/、> 结束
+//	2、属性名后遇到 = 时读取属性值，引号包围的值读取到对应的结束引号，否则读取到空白或 >
+//	3、没有值的属性（如 disabled）记录为空值、空引号，输出时只输出属性名
 func (t *Tag) Parse() {
 	if t.hasAttr && nil == t.attrs {
-		var flag byte
-		var pair []string
-		var key, val string
-		var pos, quoteN int
-		var quote, isFirst, findIt bool
+		var key, val, quote string
+		var start, end int
+		var pos = 1
 		var length = len(t.value)
 
 		t.attrs = make([]*TagAttr, 0, 10)
-		for k, v := range t.value {
-			if ' ' == v || 2 == quoteN || k+1 == length || (k+2 == length && '/' == t.value[k+1]) {
-				if !isFirst {
-					isFirst = true
+		for pos < length && -1 == strings.IndexByte("\r\n\t />", t.value[pos]) {
+			pos++
+		}
+		for pos < length {
+			if -1 != strings.IndexByte("\r\n\t /", t.value[pos]) {
+				pos++
 
-					continue
-				} else if quote {
-					continue
-				} else if findIt {
-					if (k+1 < length && (' ' == t.value[k+1] || '=' == t.value[k+1])) || (k > 0 && (' ' == t.value[k-1] || '=' == t.value[k-1])) {
-						continue
-					}
+				continue
+			} else if '>' == t.value[pos] {
+				break
+			}
 
-					if 2 == quoteN && flag != t.value[pos-1] {
-						pair = strings.SplitN(t.value[pos-1:k], "=", 2)
-					} else {
-						pair = strings.SplitN(t.value[pos:k], "=", 2)
-					}
-					if 2 == len(pair) {
-						key = strings.Trim(pair[0], "\r\n\t\"' ")
-						val = strings.Trim(pair[1], "/> ")
-
-						if "" != key && "" != val {
-							t.attrs = append(t.attrs, &TagAttr{
-								state:        true,
-								lowerName:    strings.ToLower(key),
-								originalName: key,
-								value:        strings.Trim(val, val[0:1]+" "),
-								quote:        val[0:1],
-							})
-						}
-					}
+			start = pos
+			for pos < length && -1 == strings.IndexByte("\r\n\t =/>", t.value[pos]) {
+				pos++
+			}
+			if start == pos {
+				// 没有属性名的 =，跳过
+				pos++
 
-					quoteN = 0
-					findIt = false
+				continue
+			}
+
+			key, val, quote = t.value[start:pos], "", ""
+			end = pos
+			for end < length && -1 != strings.IndexByte("\r\n\t ", t.value[end]) {
+				end++
+			}
+			if end < length && '=' == t.value[end] {
+				pos = end + 1
+				for pos < length && -1 != strings.IndexByte("\r\n\t ", t.value[pos]) {
+					pos++
 				}
-			} else if '"' == v {
-				if !quote {
-					flag = '"'
-					quote = true
-					if findIt {
-						quoteN++
+				if pos < length && ('"' == t.value[pos] || '\'' == t.value[pos]) {
+					quote = t.value[pos : pos+1]
+					if end = strings.IndexByte(t.value[pos+1:], t.value[pos]); -1 == end {
+						val, pos = strings.TrimRight(t.value[pos+1:], "/> "), length
+					} else {
+						val, pos = t.value[pos+1:pos+1+end], pos+end+2
 					}
-				} else if quote && '"' == flag && k > 0 && '\\' != v {
-					quote = false
-					if findIt {
-						quoteN++
+				} else {
+					start = pos
+					for pos < length && -1 == strings.IndexByte("\r\n\t >", t.value[pos]) {
+						pos++
 					}
+
+					val, quote = strings.TrimRight(t.value[start:pos], "/"), "\""
 				}
-			} else if '\'' == v {
-				if !quote {
-					quote = true
-					flag = '\''
-				} else if quote && '\'' == flag && k > 0 && '\\' != v {
-					quote = false
-				}
-			} else if isFirst && !quote && !findIt && '=' != v {
-				pos = k
-				findIt = true
 			}
+
+			t.attrs = append(t.attrs, &TagAttr{
+				state:        true,
+				lowerName:    strings.ToLower(key),
+				originalName: key,
+				value:        val,
+				quote:        quote,
+			})
 		}
 	}
 }
@@ -178,83 +188,6 @@ func (t *Tag) Get(attr string) *TagAttr {
 	return nil
 }
 
-// Match 标签是否匹配选择器
-func (t *Tag) Match(selector *TagSelector) bool {
-	var val string
-	var attr *TagAttr
-	var values []string
-	var result = true
-
-	if t.hasAttr {
-		if "class" == selector.Type {
-			if "" != selector.Tag && t.name != selector.Tag {
-				result = false
-			} else {
-				result = false
-
-				if attr = t.Get(selector.Attr); nil != attr {
-					values = strings.Split(attr.value, " ")
-					for _, val = range values {
-						for _, sel := range selector.Value {
-							if sel == val {
-								result = true
-
-								break
-							}
-						}
-						if result {
-							break
-						}
-					}
-				}
-			}
-		} else if "id" == selector.Type {
-			if "" != selector.Tag && t.name != selector.Tag {
-				result = false
-			} else {
-				if attr = t.Get("id"); nil != attr && attr.value == selector.Value[0] {
-					result = true
-				} else if attr = t.Get("name"); nil != attr && attr.value == selector.Value[0] {
-					result = true
-				} else {
-					result = false
-				}
-			}
-		} else if "attr" == selector.Type {
-			if "" != selector.Tag && t.name != selector.Tag {
-				result = false
-			} else {
-				if attr = t.Get(selector.Attr); nil == attr {
-					result = false
-				} else {
-					if "" != selector.Value[0] {
-						result = false
-						if "*" == selector.Value[0] {
-							result = true
-						} else if '^' == selector.Value[0][0] && strings.HasPrefix(attr.value, selector.Value[0][1:]) {
-							result = true
-						} else if '$' == selector.Value[0][0] && strings.HasSuffix(attr.value, selector.Value[0][1:]) {
-							result = true
-						} else if '~' == selector.Value[0][0] && strings.Contains(attr.value, selector.Value[0][1:]) {
-							result = true
-						} else if attr.value == selector.Value[0] {
-							result = true
-						}
-					}
-				}
-			}
-		} else if "tag" == selector.Type {
-			result = t.name == selector.Tag
-		} else {
-			result = false
-		}
-	} else {
-		result = "tag" == selector.Type && t.name == selector.Tag
-	}
-
-	return result
-}
-
 // StripEvent 去掉事件
 func (t *Tag) StripEvent() {
 	if t.hasAttr && ("start" == t.category || "self" == t.category) {
@@ -275,7 +208,8 @@ func (t *Tag) StripEmpty() {
 		t.Parse()
 
 		for _, v := range t.attrs {
-			if "" == v.value {
+			// 没有值的属性（如 disabled）不是空属性
+			if "" == v.value && "" != v.quote {
 				v.state = false
 				t.dynamic = true
 			}
@@ -301,12 +235,72 @@ func (t *Tag) StripAttr(names []string) {
 	}
 }
 
-// TagSelector 标签选择器
-type TagSelector struct {
-	Type  string   `label:"类型"`
-	Tag   string   `label:"标签"`
-	Attr  string   `label:"属性名"`
-	Value []string `label:"属性值"`
+// SetAttr 设置属性值，属性不存在时添加
+func (t *Tag) SetAttr(name string, value string) {
+	var lowerName = strings.ToLower(name)
+
+	t.Parse()
+	t.dynamic = true
+	value = strings.ReplaceAll(value, "\"", "&quot;")
+	for _, v := range t.attrs {
+		if v.lowerName == lowerName {
+			v.state = true
+			v.value = value
+			v.quote = "\""
+
+			return
+		}
+	}
+
+	t.hasAttr = true
+	t.attrs = append(t.attrs, &TagAttr{
+		state:        true,
+		lowerName:    lowerName,
+		originalName: name,
+		value:        value,
+		quote:        "\"",
+	})
+}
+
+// AddClass 添加类名
+func (t *Tag) AddClass(names []string) {
+	var classes []string
+
+	if attr := t.Get("class"); nil != attr {
+		classes = strings.Fields(attr.value)
+	}
+	for _, name := range names {
+		if !t.hasClass(name) {
+			classes = append(classes, name)
+		}
+	}
+
+	t.SetAttr("class", strings.Join(classes, " "))
+}
+
+// RemoveClass 删除类名，类名全部删除后去掉 class 属性
+func (t *Tag) RemoveClass(names []string) {
+	var attr = t.Get("class")
+	var classes []string
+	var drop = make(map[string]bool, len(names))
+
+	if nil == attr {
+		return
+	}
+	for _, name := range names {
+		drop[name] = true
+	}
+	for _, v := range strings.Fields(attr.value) {
+		if !drop[v] {
+			classes = append(classes, v)
+		}
+	}
+
+	if 0 == len(classes) {
+		t.StripAttr([]string{"class"})
+	} else {
+		t.SetAttr("class", strings.Join(classes, " "))
+	}
 }
 
 // TidyOption 清理参数
@@ -316,14 +310,28 @@ type TidyOption struct {
 	SkipEmptyAttr bool           `label:"去除空属性"`
 	SkipComment   bool           `label:"去除注释"`
 	EscapeBracket bool           `label:"转义括号"`
+	CollapseLinks bool           `label:"把多级链接改为直接指向词条"`
+	DropLinks     string         `label:"去除的无效链接"`
+	Workers       int            `label:"并发整理的协程数"`
+	Encoding      string         `label:"输入文件编码"`
+	OutEncoding   string         `label:"输出文件编码"`
 	Input         string         `label:"输入文件"`
 	Style         string         `label:"Style文件"`
 	Output        string         `label:"输出文件"`
+	LinkReport    string         `label:"链接检查报告文件"`
+	Duplicate     string         `label:"重复词头处理策略"`
+	DupSeparator  string         `label:"合并重复词头正文的分隔模板"`
+	DupVariant    string         `label:"重复词头编号模板"`
+	DupReport     string         `label:"重复词头处理报告文件"`
+	DeadClass     string         `label:"css 入口生成的概览文件，去掉其中没有样式的类名"`
 	Drop          []string       `label:"删除的标签"`
 	UnWrap        []string       `label:"解开的标签"`
 	SkipContent   []string       `label:"跳过的内容"`
-	Prepare       [][2]string    `label:"预替换的关键词"`
-	Post          [][2]string    `label:"后替换的关键词"`
+	Prepare       []*Replacement `label:"预替换的关键词"`
+	Post          []*Replacement `label:"后替换的关键词"`
+	Rules         []*TidyRule    `label:"整理规则"`
+	rules         []*TidyRule    `label:"执行的整理规则"`
+	scope         *TagSelector   `label:"规则作用范围"`
 	selDrop       []*TagSelector `label:"删除的标签"`
 	selUnWrap     []*TagSelector `label:"删除的标签"`
 }
@@ -351,18 +359,35 @@ func (o *TidyOption) Init() error {
 		}
 	}
 
-	if len(o.Drop) > 0 {
-		o.selDrop = make([]*TagSelector, len(o.Drop))
-		for k, v := range o.Drop {
-			o.selDrop[k] = o.parseSelector(v)
-		}
+	if o.Workers < 1 {
+		o.Workers = runtime.NumCPU()
 	}
-	if len(o.UnWrap) > 0 {
-		o.selUnWrap = make([]*TagSelector, len(o.UnWrap))
-		for k, v := range o.UnWrap {
-			o.selUnWrap[k] = o.parseSelector(v)
-		}
+	if o.Encoding, err = checkEncoding("Encoding", o.Encoding); nil != err {
+		msg = append(msg, err.Error())
+	}
+	if o.OutEncoding, err = checkEncoding("OutEncoding", o.OutEncoding); nil != err {
+		msg = append(msg, err.Error())
+	}
+	if err = checkDropLinks(o.DropLinks); nil != err {
+		msg = append(msg, err.Error())
+	}
+	if err = checkDupPolicy(o.Duplicate); nil != err {
+		msg = append(msg, err.Error())
+	}
+	if "" == o.DupSeparator {
+		o.DupSeparator = "<hr/>"
 	}
+	if "" == o.DupVariant {
+		o.DupVariant = "{word} ({n})"
+	} else if -1 == strings.Index(o.DupVariant, "{n}") {
+		msg = append(msg, "重复词头编号模板 DupVariant 必须包含 {n}")
+	}
+
+	msg = append(msg, o.initSelectors()...)
+	msg = append(msg, o.initRules()...)
+	msg = append(msg, o.initDeadClass()...)
+	msg = append(msg, initReplacements("预替换 Prepare", o.Prepare)...)
+	msg = append(msg, initReplacements("后替换 Post", o.Post)...)
 
 	if len(msg) > 0 {
 		err = errors.New(strings.Join(msg, "\n"))
@@ -371,71 +396,72 @@ func (o *TidyOption) Init() error {
 	return err
 }
 
-// parseSelector 解析选择器
-func (o *TidyOption) parseSelector(selector string) *TagSelector {
-	var pos int
-	var sel *TagSelector
-	var pair, values []string
-
-	if pos = strings.Index(selector, "."); -1 != pos {
-		pair = strings.Split(selector, ".")
-		sel = &TagSelector{
-			Type:  "class",
-			Tag:   pair[0],
-			Attr:  "class",
-			Value: pair[1:],
-		}
-	} else if pos = strings.Index(selector, "#"); -1 != pos {
-		pair = strings.SplitN(selector, "#", 2)
-		sel = &TagSelector{
-			Type:  "id",
-			Tag:   pair[0],
-			Attr:  "id",
-			Value: pair[1:],
-		}
-	} else if pos = strings.Index(selector, "["); -1 != pos {
-		pair = strings.SplitN(selector, "[", 2)
-		values = strings.SplitN(strings.Trim(pair[1], "] "), "=", 2)
-		sel = &TagSelector{
-			Type:  "attr",
-			Tag:   pair[0],
-			Attr:  strings.Trim(values[0], " "),
-			Value: []string{strings.Trim(values[1], "\r\n\t\"' ")},
+// initSelectors 解析 Drop 与 UnWrap 选择器，返回错误信息
+func (o *TidyOption) initSelectors() []string {
+	var msg = make([]string, 0, 2)
+
+	if len(o.Drop) > 0 {
+		o.selDrop = make([]*TagSelector, 0, len(o.Drop))
+		for _, v := range o.Drop {
+			if sel, err := ParseSelector(v); nil == err {
+				o.selDrop = append(o.selDrop, sel)
+			} else {
+				msg = append(msg, "删除的标签 Drop 中的"+err.Error())
+			}
 		}
-	} else {
-		sel = &TagSelector{
-			Type: "tag",
-			Tag:  selector,
+	}
+	if len(o.UnWrap) > 0 {
+		o.selUnWrap = make([]*TagSelector, 0, len(o.UnWrap))
+		for _, v := range o.UnWrap {
+			if sel, err := ParseSelector(v); nil == err {
+				o.selUnWrap = append(o.selUnWrap, sel)
+			} else {
+				msg = append(msg, "解开的标签 UnWrap 中的"+err.Error())
+			}
 		}
 	}
 
-	return sel
+	return msg
+}
+
+// inScope 标签是否在规则的作用范围内，即标签自身或上级标签匹配作用范围选择器
+func (o *TidyOption) inScope(d *Dom, tag *Tag) bool {
+	if nil == o.scope {
+		return true
+	}
+
+	for ; nil != tag; tag = d.Parent(tag) {
+		if d.Match(tag, o.scope) {
+			return true
+		}
+	}
+
+	return false
 }
 
 // CSSOption CSS 整理选项
 type CSSOption struct {
 	separator string   `label:"CSS换行分隔符"`
+	Quick     bool     `label:"只按源文件选择器概览检查选择器"`
+	Samples   int      `label:"没有样式的类名记录的词头示例数"`
+	Encoding  string   `label:"源文件编码"`
 	Source    string   `label:"源文件路径"`
 	CSS       string   `label:"CSS源文件路径"`
 	Output    string   `label:"CSS保存文件路径"`
 	Summary   string   `label:"源文件选择器概览"`
+	Report    string   `label:"选择器检查报告"`
 	SkipID    []string `label:"忽略的 CSS ID"`
 	SkipClass []string `label:"忽略的 CSS CLASS"`
 	SkipAttr  []string `label:"忽略的标签属性"`
 }
 
-// MergeOption 词典合并选项
-type MergeOption struct {
-	Source string `label:"源词典文件"`
-	Target string `label:"合并到的词典文件"`
-	Output string `label:"输出的词典文件"`
-}
-
 // Dom 文档标签树
 type Dom struct {
-	idx  int64   `label:"标签下标"`
-	sub  []int64 `label:"子节点列表"`
-	root []*Tag  `label:"标签列表"`
+	idx      int64            `label:"标签下标"`
+	sub      []int64          `label:"子节点列表"`
+	root     []*Tag           `label:"标签列表"`
+	nodes    map[int64]*Tag   `label:"标签索引"`
+	children map[int64][]*Tag `label:"下级标签索引"`
 }
 
 // GetSubIdx 返回搜索结果的节点号
@@ -455,7 +481,7 @@ func (d *Dom) RangeToString(s int64, e int64, textOnly bool) string {
 	for _, tag = range d.root {
 		if tag.state && tag.id >= s && tag.id <= e {
 			if !textOnly || (textOnly && "content" == tag.category) {
-				buf.WriteString(tag.value)
+				buf.WriteString(tag.String())
 			}
 		}
 	}
@@ -497,7 +523,7 @@ func (d *Dom) Find(selector *TagSelector) *Dom {
 		if skip > 0 && tag.id < skip {
 			continue
 		}
-		if tag.state && ("start" == tag.category || "self" == tag.category) && tag.Match(selector) {
+		if tag.state && d.Match(tag, selector) {
 			skip = tag.close
 			sub = append(sub, tag.id)
 		}
@@ -578,6 +604,7 @@ func (d *Dom) Insert(value string, idx int64, after bool) {
 
 		d.idx++
 		d.root = append(d.root, nTag)
+		d.reindex()
 
 		sort.Slice(d.root, func(i int, j int) bool {
 			return d.root[i].id < d.root[j].id
@@ -625,9 +652,9 @@ func (d *Dom) Tidy(entry *Entry, opt *TidyOption) {
 
 			continue
 		}
-		if ("start" == tag.category || "self" == tag.category) && "" != tag.name {
+		if ("start" == tag.category || "self" == tag.category) && "" != tag.name && (len(opt.selDrop) > 0 || len(opt.selUnWrap) > 0) && opt.inScope(d, tag) {
 			for _, r := range opt.selDrop {
-				if tag.Match(r) {
+				if d.Match(tag, r) {
 					tag.Drop()
 					if tag.close > 0 {
 						dropClose = tag.close
@@ -640,7 +667,7 @@ func (d *Dom) Tidy(entry *Entry, opt *TidyOption) {
 				continue
 			}
 			for _, r := range opt.selUnWrap {
-				if tag.Match(r) {
+				if d.Match(tag, r) {
 					tag.Drop()
 					if tag.close > 0 {
 						unWrapID[tag.close] = true
@@ -749,12 +776,15 @@ func (d *Dom) Tidy(entry *Entry, opt *TidyOption) {
 			}
 
 			d.idx++
-			d.root = append(d.root, &Tag{state: true, id: d.idx*10000 + 5000, parent: tag.parent, category: "close", name: tag.name, value: "</" + tag.name + ">"})
+			tag.close = d.idx*10000 + 5000
+			d.root = append(d.root, &Tag{state: true, id: tag.close, parent: tag.parent, category: "close", name: tag.name, value: "</" + tag.name + ">"})
 		} else {
 			break
 		}
 	}
 
+	d.reindex()
+
 	sort.Slice(d.root, func(i int, j int) bool {
 		return d.root[i].id < d.root[j].id
 	})
@@ -954,8 +984,7 @@ func splitMdxData(data []byte) []*Entry {
 
 // stripBlockHoleEntry 去除无效的词链接
 func stripBlockHoleEntry(in []*Entry) []*Entry {
-	var ok bool
-	var miss = 0
+	var dropped map[string]bool
 	var out = make([]*Entry, 0, len(in))
 	var link = make(map[string]string, 100)
 	var mapper = make(map[string]bool, len(in))
@@ -968,32 +997,16 @@ func stripBlockHoleEntry(in []*Entry) []*Entry {
 		}
 	}
 
-	for {
-		miss = 0
-		for k, v := range link {
-			if _, ok = link[v]; !ok && !mapper[v] {
-				delete(link, k)
-				miss++
-			}
-		}
-		if miss == 0 {
-			break
-		}
-	}
-
+	dropped = analyzeLinks(mapper, link).missing
 	for _, v := range in {
 		if len(v.word) > 1024 {
 			fmt.Println("long word:", v.word)
 		}
-		if "link" == strings.ToLower(v.action) {
-			if _, ok = link[v.word]; ok {
-				out = append(out, v)
-			} else {
-				//fmt.Println(v.word)
-			}
-		} else {
-			out = append(out, v)
+		if "link" == strings.ToLower(v.action) && dropped[v.word] {
+			continue
 		}
+
+		out = append(out, v)
 	}
 
 	return out
@@ -1107,6 +1120,8 @@ func parseBodyItem(element *Entry, data string) *Dom {
 				} else {
 					tag.name = data[startPos+1 : endPos-1]
 				}
+
+				tag.hasAttr = pos > 0 && pos < endPos && "" != strings.Trim(data[pos:endPos], "\r\n\t /")
 			} else {
 				tag = &Tag{
 					state:    true,
@@ -1129,12 +1144,16 @@ func parseBodyItem(element *Entry, data string) *Dom {
 				}
 				if "start" == tag.category || "self" == tag.category {
 					tag.value = stripSpaceMore(tag.value)
-					tag.hasAttr = strings.Index(tag.value, "=") > 0
+					// 标签名后有任何内容即有属性，包括 disabled 这类没有值的属性
+					tag.hasAttr = pos > 0 && pos < endPos && "" != strings.Trim(data[pos:endPos], "\r\n\t /")
 				}
 			}
 
 			tag.id = cur*10000 + 5000
 			tag.parent = parent
+			if tag.pos = startPos; isComment {
+				tag.pos = lastPos
+			}
 			if "start" == tag.category {
 				parent = tag.id
 				tagStack = append(tagStack, tag)
@@ -1170,6 +1189,7 @@ func parseBodyItem(element *Entry, data string) *Dom {
 				state:    true,
 				id:       cur*10000 + 5000,
 				parent:   parent,
+				pos:      lastPos,
 				category: "content",
 				value:    data[lastPos:idx],
 			}
@@ -1186,6 +1206,7 @@ func parseBodyItem(element *Entry, data string) *Dom {
 					state:    true,
 					id:       cur*10000 + 5000,
 					parent:   parent,
+					pos:      lastPos,
 					category: "content",
 					value:    data[lastPos:],
 				})
@@ -1275,16 +1296,23 @@ func prepareStyle(body []byte, style *map[string][2]string) string {
 //	5、将整理后的词典内容拼为源文件
 //	6、按配置替换掉关键词内容
 func tidyMdict(cfg string) error {
-	var idx int
-	var err error
-	var dom *Dom
+	var temp, scanned bool
+	var err, werr error
+	var offset int64
+	var file string
+	var fp *os.File
+	var reader *EntryReader
 	var element *Entry
-	var rawStyle [][]byte
-	var data []byte
-	var elements []*Entry
-	var container []string
+	var chunk []byte
+	var body string
+	var job *tidyJob
+	var src *os.File
+	var dup *DupCopy
+	var group *DupGroup
+	var dups *DupReport
+	var report *LinkReport
 	var style map[string][2]string
-	var word, body, newBody, content string
+	var wg sync.WaitGroup
 
 	var opt = new(TidyOption)
 	if err = LoadJSON(cfg, opt); nil != err {
@@ -1294,92 +1322,375 @@ func tidyMdict(cfg string) error {
 		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
 	}
 
-	fmt.Println("read file before")
+	if opt.Input, temp, err = decodeFile(opt.Input, opt.Encoding); nil != err {
+		return err
+	}
+	if temp {
+		defer func() {
+			_ = os.Remove(opt.Input)
+		}()
+	}
+
 	if "" != opt.Style {
-		if data, err = os.ReadFile(opt.Style); nil == err {
-			style = make(map[string][2]string, 25)
-			rawStyle = bytes.Split(data, []byte{'\n'})
-			for k, v := range rawStyle {
-				if 0 == (k+1)%3 {
-					word = string(bytes.Trim(rawStyle[k-2], "\r\n\t "))
-					style[word] = [2]string{
-						string(bytes.Trim(rawStyle[k-1], "\r\n\t ")),
-						string(bytes.Trim(v, "\r\n\t ")),
-					}
-				}
+		if style, err = loadStyle(opt.Style); nil != err {
+			return err
+		}
+	}
+
+	if file, report, dups, err = opt.scanEntries(); nil != err {
+		return err
+	}
+	if scanned = "" != file; !scanned {
+		file = opt.Input
+	} else if file != opt.Input {
+		defer func() {
+			_ = os.Remove(file)
+		}()
+	}
+	if "concat" == opt.Duplicate {
+		if src, err = os.Open(file); nil != err {
+			return err
+		}
+		defer func() {
+			_ = src.Close()
+		}()
+	}
+
+	if reader, err = OpenEntryReader(file); nil != err {
+		return err
+	}
+	defer reader.Close()
+
+	if fp, err = os.OpenFile(opt.Output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	// 词条由多个协程并发整理，queue 按读取顺序保存任务，写入协程依次等待每个任务的结果
+	var jobs = make(chan *tidyJob, opt.Workers*4)
+	var queue = make(chan *tidyJob, opt.Workers*64)
+	var done = make(chan error, 1)
+
+	for i := 0; i < opt.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range jobs {
+				v.result <- opt.tidyEntry(v.element, v.body, style)
 			}
+		}()
+	}
+	go func() {
+		done <- writeEntries(fp, queue)
+	}()
+
+	fmt.Println("start data process, workers:", opt.Workers)
+	for {
+		if chunk, offset, err = reader.Next(); nil != err {
+			break
+		}
+		// 第一遍已执行预替换，不再重复执行
+		if scanned {
+			element, body = parseBody(chunk, 0, len(chunk)), string(chunk)
 		} else {
-			return err
+			element, body = opt.prepareEntry(chunk)
+		}
+		if nil == element {
+			continue
+		}
+		if "link" == strings.ToLower(element.action) {
+			if nil != report && report.drop(element.word, opt.DropLinks) {
+				continue
+			}
+			if nil != report && opt.CollapseLinks && "" != report.final[element.word] && report.final[element.word] != element.value {
+				element.value = report.final[element.word]
+				body = element.word + "\r\n@@@LINK=" + element.value
+			}
+		} else if group, dup = dups.Next(element.word); nil != dup {
+			switch dup.Action {
+			case "drop", "merge":
+				continue
+			case "rename":
+				element.word = dup.Word
+				body = renameEntry(body, dup.Word)
+			case "concat":
+				body, err = opt.concatEntry(src, group, body)
+			}
+			if nil != err {
+				break
+			}
+		}
+
+		element.offset = offset
+		if opt.DumpWord {
+			fmt.Println(element.word)
+			continue
 		}
+
+		job = &tidyJob{element: element, body: body, result: make(chan string, 1)}
+		queue <- job
+		jobs <- job
 	}
 
-	if data, err = os.ReadFile(opt.Input); nil != err {
+	close(jobs)
+	close(queue)
+	wg.Wait()
+	werr = <-done
+
+	if io.EOF != err {
 		return err
 	}
+	if nil == werr && "" != opt.OutEncoding {
+		_ = fp.Close()
+		werr = encodeFile(opt.Output, opt.OutEncoding)
+	}
+
+	return werr
+}
+
+// tidyJob 词条整理任务
+type tidyJob struct {
+	element *Entry      `label:"词条信息"`
+	body    string      `label:"词条内容"`
+	result  chan string `label:"整理结果"`
+}
 
-	if 0xef == data[0] && 0xbb == data[1] && 0xbf == data[2] {
-		data = data[3:]
+// writeEntries 按任务顺序写入整理结果，写入失败后继续取出结果，避免整理协程阻塞
+func writeEntries(fp *os.File, queue chan *tidyJob) error {
+	var num int
+	var err error
+	var newBody string
+	var buf = bufio.NewWriterSize(fp, 1<<20)
+
+	for job := range queue {
+		if newBody = <-job.result; "" == newBody || nil != err {
+			continue
+		}
+
+		if num > 0 {
+			_, _ = buf.WriteString("\r\n</>\r\n")
+		}
+		_, err = buf.WriteString(newBody)
+
+		if num++; 0 == num%50000 {
+			fmt.Println("start processed:", num)
+		}
 	}
+	if nil != err {
+		return err
+	}
+
+	fmt.Println("start processed:", num)
 
-	fmt.Println("read file done")
+	return buf.Flush()
+}
+
+// loadStyle 读取 .Style 样式表，每三行为一组：编号、开始标记、结束标记
+func loadStyle(file string) (map[string][2]string, error) {
+	var word string
+	var rawStyle [][]byte
+	var data, err = os.ReadFile(file)
+	var style = make(map[string][2]string, 25)
 
-	if len(opt.Prepare) > 0 {
-		fmt.Println("prepare file start")
-		for _, item := range opt.Prepare {
-			data = bytes.ReplaceAll(data, []byte(item[0]), []byte(item[1]))
+	if nil != err {
+		return nil, err
+	}
+
+	rawStyle = bytes.Split(data, []byte{'\n'})
+	for k, v := range rawStyle {
+		if 0 == (k+1)%3 {
+			word = string(bytes.Trim(rawStyle[k-2], "\r\n\t "))
+			style[word] = [2]string{
+				string(bytes.Trim(rawStyle[k-1], "\r\n\t ")),
+				string(bytes.Trim(v, "\r\n\t ")),
+			}
+		}
+	}
+
+	return style, nil
+}
+
+// scanEntries 需要检查链接、重复词头或提取内嵌块时第一遍读取词典源文件，返回第二遍读取的文件，不需要第一遍读取时返回空字符串
+//
+// 实现思路：
+//
+//	1、只在设置了 CollapseLinks、DropLinks、LinkReport、Duplicate、DupReport 或 Extract 规则时读取，否则只读取一遍，内存占用与词条数量无关
+//	2、检查链接与重复词头时保存全部词头，内存占用随词头数量增长
+//	3、有预替换时把替换后的词条写入临时文件，第二遍读取临时文件，预替换只执行一次，重复词头的位置为临时文件中的位置
+func (o *TidyOption) scanEntries() (string, *LinkReport, *DupReport, error) {
+	var err error
+	var offset, size int64
+	var chunk []byte
+	var body string
+	var element *Entry
+	var reader *EntryReader
+	var fp *os.File
+	var buf *bufio.Writer
+	var report *LinkReport
+	var dups *DupReport
+	var words map[string]bool
+	var links map[string]string
+	var extract = make([]*TidyRule, 0, 1)
+	var file = o.Input
+	var checkLinks = o.CollapseLinks || "" != o.DropLinks || "" != o.LinkReport
+	var checkDups = "" != o.Duplicate || "" != o.DupReport
+
+	for _, rule := range o.rules {
+		if "Extract" == rule.Action {
+			extract = append(extract, rule)
 		}
+	}
+	if !checkLinks && !checkDups && 0 == len(extract) {
+		return "", nil, nil, nil
+	}
 
-		fmt.Println("prepare file done")
+	if reader, err = OpenEntryReader(o.Input); nil != err {
+		return "", nil, nil, err
 	}
+	defer reader.Close()
 
-	fmt.Println("split words")
-	elements = splitMdxData(data)
-	container = make([]string, 0, len(elements))
-	fmt.Println("start data process")
+	if len(o.Prepare) > 0 {
+		if fp, err = os.CreateTemp(filepath.Dir(o.Output), "tidy-*.txt"); nil != err {
+			return "", nil, nil, err
+		}
 
-	for _, element = range elements {
-		idx++
+		file = fp.Name()
+		buf = bufio.NewWriterSize(fp, 1<<20)
+		defer func() {
+			_ = fp.Close()
+			if nil != err {
+				_ = os.Remove(file)
+			}
+		}()
+	}
+	if checkLinks || checkDups {
+		words = make(map[string]bool, 100000)
+		links = make(map[string]string, 100)
+	}
+	if checkDups {
+		dups = newDupReport(o.Duplicate)
+	}
 
-		if opt.DumpWord {
-			fmt.Println(word)
+	fmt.Println("scan entries")
+	for {
+		if chunk, offset, err = reader.Next(); nil != err {
+			break
+		}
+		if element, body = o.prepareEntry(chunk); nil == element {
 			continue
 		}
+		if nil != buf {
+			if _, err = buf.WriteString(body + "\r\n</>\r\n"); nil != err {
+				break
+			}
+
+			offset = size
+			size += int64(len(body)) + 7
+		}
+
+		if "link" == strings.ToLower(element.action) {
+			if nil != links {
+				links[element.word] = element.value
+			}
 
-		if body = stripSpace(string(data[element.start:element.end])); len(body) < 1 {
 			continue
 		}
+		if nil != words {
+			words[element.word] = true
+		}
+		if nil != dups {
+			dups.Add(element.word, offset, len(body))
+		}
+		for _, rule := range extract {
+			rule.countBlocks(body)
+		}
+	}
+	if io.EOF != err {
+		return "", nil, nil, err
+	}
+	if nil != buf {
+		if err = buf.Flush(); nil != err {
+			return "", nil, nil, err
+		}
+	}
 
-		if nil == style {
-			dom = parseBodyItem(element, body)
-		} else {
-			dom = parseBodyItem(element, prepareStyle([]byte(body), &style))
+	for _, rule := range extract {
+		if err = rule.saveBlocks(); nil != err {
+			return "", nil, nil, err
 		}
+	}
 
-		dom.Tidy(element, opt)
-		newBody = dom.ToString(false)
-		if float64(len(body))*1.3 < float64(len(newBody)) {
-			fmt.Println("entry [" + element.word + "] parse failed, may be body incorrect")
+	if nil != dups {
+		dups.Decide(words, o.DupVariant)
+		dups.Print()
+		if "" != o.DupReport {
+			if err = dups.Save(o.DupReport); nil != err {
+				return "", nil, nil, err
+			}
 		}
-		if "" == newBody {
-			continue
+	}
+
+	if checkLinks {
+		report = analyzeLinks(words, links)
+		report.Print()
+		if "" != o.LinkReport {
+			if err = report.Save(o.LinkReport); nil != err {
+				return "", nil, nil, err
+			}
 		}
+	}
+
+	return file, report, dups, nil
+}
 
-		container = append(container, newBody)
-		if idx > 0 && (0 == idx%50000 || idx+1 == len(elements)) {
-			fmt.Println("start processed:", idx)
+// prepareEntry 对词条执行预替换，返回词条信息与替换后的词条内容
+func (o *TidyOption) prepareEntry(chunk []byte) (*Entry, string) {
+	var data []byte
+	var element *Entry
+
+	for _, item := range o.Prepare {
+		if "" == item.Scope {
+			chunk = item.ReplaceBytes(chunk)
 		}
 	}
 
-	content = strings.Join(container, "\r\n</>\r\n")
-	if len(opt.Post) > 0 {
-		fmt.Println("post process start")
-		for _, item := range opt.Post {
-			content = strings.ReplaceAll(content, item[0], item[1])
+	data = []byte(replaceEntry(string(chunk), o.Prepare))
+	if element = parseBody(data, 0, len(data)); nil != element {
+		if len(element.word) > 1024 {
+			fmt.Println("long word:", element.word)
 		}
-		fmt.Println("post process done")
 	}
 
-	return FilePutContents(opt.Output, []byte(content), false)
+	return element, string(data)
+}
+
+// tidyEntry 整理单个词条，返回整理后的词条内容，内容为空时返回空字符串
+func (o *TidyOption) tidyEntry(element *Entry, data string, style map[string][2]string) string {
+	var dom *Dom
+	var newBody string
+	var body = stripSpace(data)
+
+	if len(body) < 1 {
+		return ""
+	}
+
+	if nil == style {
+		dom = parseBodyItem(element, body)
+	} else {
+		dom = parseBodyItem(element, prepareStyle([]byte(body), &style))
+	}
+
+	dom.ReplaceText(o.Prepare)
+	dom.Apply(element, o.rules)
+	dom.ReplaceText(o.Post)
+	newBody = replaceAll(replaceEntry(dom.ToString(false), o.Post), o.Post, "")
+	if float64(len(body))*1.3 < float64(len(newBody)) {
+		fmt.Println("entry [" + element.word + "] parse failed, may be body incorrect")
+	}
+
+	return newBody
 }
 
 // getSourceUsage 返回源文件中用到的标签属性
@@ -1399,7 +1710,7 @@ func getSourceUsage(opt *CSSOption) (map[string]map[string]int, error) {
 	if nil == err {
 		length = len(data)
 		for _, v := range opt.SkipAttr {
-			skipAttr[v] = true
+			skipAttr[strings.ToLower(v)] = true
 		}
 
 		for k, v := range data {
@@ -1413,8 +1724,7 @@ func getSourceUsage(opt *CSSOption) (map[string]map[string]int, error) {
 			} else if '>' == v && findIt && hasSpace && spacePos > pos+1 {
 				findIt = false
 				hasSpace = false
-				tag = &Tag{name: strings.ToLower(string(data[pos+1 : spacePos])), value: string(data[pos : k+1])}
-				tag.name = string(data[pos+1 : spacePos])
+				tag = &Tag{hasAttr: true, name: strings.ToLower(string(data[pos+1 : spacePos])), value: string(data[pos : k+1])}
 
 				tag.Parse()
 				if _, ok = ret["tag"][tag.name]; ok {
@@ -1423,27 +1733,27 @@ func getSourceUsage(opt *CSSOption) (map[string]map[string]int, error) {
 					ret["tag"][tag.name] = 1
 				}
 				for _, va := range tag.attrs {
-					if skipAttr[va.originalName] {
+					if skipAttr[va.lowerName] {
 						continue
 					}
-					if _, ok = ret[va.originalName]; !ok {
-						ret[va.originalName] = make(map[string]int)
+					if _, ok = ret[va.lowerName]; !ok {
+						ret[va.lowerName] = make(map[string]int)
 					}
 
-					if "class" == va.originalName {
-						pair = strings.Split(va.value, " ")
+					if "class" == va.lowerName {
+						pair = strings.Fields(va.value)
 						for _, ca := range pair {
-							if _, ok = ret[va.originalName][ca]; ok {
-								ret[va.originalName][ca]++
+							if _, ok = ret[va.lowerName][ca]; ok {
+								ret[va.lowerName][ca]++
 							} else {
-								ret[va.originalName][ca] = 1
+								ret[va.lowerName][ca] = 1
 							}
 						}
 					} else {
-						if _, ok = ret[va.originalName][va.value]; ok {
-							ret[va.originalName][va.value]++
+						if _, ok = ret[va.lowerName][va.value]; ok {
+							ret[va.lowerName][va.value]++
 						} else {
-							ret[va.originalName][va.value] = 1
+							ret[va.lowerName][va.value] = 1
 						}
 					}
 				}
@@ -1473,76 +1783,18 @@ func getSourceUsage(opt *CSSOption) (map[string]map[string]int, error) {
 	return ret, err
 }
 
-// getCSSUsage 返回样式 CSS 资源
-func getCSSUsage(opt *CSSOption) ([][3]string, error) {
-	var findIt bool
-	var key, val string
-	var pos, fPos, nCr, nLn int
-	var selector = make([][3]string, 0, 1000)
+// getCSSUsage 解析 CSS 文件为规则树
+func getCSSUsage(opt *CSSOption) ([]*CSSRule, error) {
+	var rules []*CSSRule
 	var data, err = os.ReadFile(opt.CSS)
 
-	if nil == err {
-		for k, v := range data {
-			if '{' == v && !findIt && k > pos {
-				findIt = true
-				key = string(bytes.Trim(data[pos:k], "\r\n\t "))
-				pos = k + 1
-				if fPos = strings.Index(key, ";"); fPos > 0 {
-					selector = append(selector, [3]string{"cmd", key[:fPos+1], ""})
-					key = strings.Trim(key[fPos+1:], "\r\n\t ")
-				} else if fPos = strings.LastIndex(key, "*/"); fPos > 0 {
-					if '/' == key[0] {
-						selector = append(selector, [3]string{"remark", key[:fPos+2], ""})
-						key = strings.Trim(key[fPos+2:], "\r\n\t ")
-					} else {
-						var remark bool
-						var content = make([]byte, 0, len(key))
-						for k1, v1 := range []byte(key) {
-							if '/' == v1 {
-								if !remark && k1+1 < len(key) && '*' == key[k1+1] {
-									remark = true
-								} else if remark && k1 > 0 && '*' == key[k1-1] {
-									remark = false
-								}
-
-								continue
-							}
-							if remark {
-								continue
-							}
-
-							content = append(content, v1)
-						}
-
-						key = strings.Trim(string(content), "\r\n\t ")
-					}
-				}
-			} else if '}' == v && k > pos {
-				findIt = false
-				val = string(bytes.TrimRight(bytes.TrimLeft(data[pos:k-1], "\r\n"), "\r\n\t "))
-				if "" != key && "" != val {
-					selector = append(selector, [3]string{"css", key, val})
-				}
-
-				pos = k + 1
-			} else if '\r' == v {
-				nCr++
-			} else if '\n' == v {
-				nLn++
-			}
-		}
-		if nCr > 0 && nLn > 0 {
-			opt.separator = "\r\n"
-		} else if nLn > 0 {
-			opt.separator = "\n"
-		} else {
-			opt.separator = "\r"
-		}
-	} else {
-		err = errors.New("解析样式表，" + err.Error())
+	if nil != err {
+		return nil, errors.New("解析样式表，" + err.Error())
 	}
 
-	return selector, err
+	rules, opt.separator = parseCSS(string(data))
+
+	return rules, nil
 }
 
 // tidyCSS 整理词典 CSS 文件
@@ -1554,21 +1806,21 @@ func getCSSUsage(opt *CSSOption) ([][3]string, error) {
 //
 // 实现思路：
 //
-//	1、提取 CSS 文件中的选择器与样式内容
+//	1、把 CSS 文件解析为规则树，@media 等规则的内部规则作为子节点，字符串与注释中的括号不影响解析
 //	2、提取词典源文件中标签与标签属性为标签概览
-//	3、循环检查 CSS 文件中的选择器是否出现在词典标签概览中
-//	4、设置检查标志，如果出现就标志为找到，否则就是未找到
-//	5、将找到的样式保存到新的样式文件中
-//	6、将标签概览保存到概览文件中，方便复查
+//	3、按标签概览去掉用到了源文件中没有的标签、类名、ID 或属性的选择器，再逐条解析源文件的 DOM 树，按上级与兄弟元素检查剩下的选择器
+//	4、@media 等规则只要有内部规则被用到就保留，没有时整个丢弃，@font-face、@keyframes 等规则原样保留
+//	5、将保留的规则保存到新的样式文件中
+//	6、将标签概览与源文件中用到但没有 CSS 规则的类名、ID、标签保存到概览文件中，方便复查，tidy 可以按概览去掉没有样式的类名
 func tidyCSS(cfg string) error {
 	var err error
 	var data []byte
-	var findIt bool
-	var selector [][3]string
-	var tag, selT, sel4, sel5 string
-	var skipID, skipClass map[string]bool
+	var temp bool
+	var usage *cssUsage
+	var summary *CSSSummary
+	var rules []*CSSRule
 	var cssInUse map[string]map[string]int
-	var cssContent, pair, sel1, sel2, sel3 []string
+	var cssContent []string
 	var opt = new(CSSOption)
 
 	if err = LoadJSON(cfg, opt); nil != err {
@@ -1597,226 +1849,74 @@ func tidyCSS(cfg string) error {
 		if "" == opt.Summary && n > 4 {
 			opt.Summary = opt.CSS[0:n-4] + ".summary.json"
 		}
+		if "" == opt.Report && n > 4 {
+			opt.Report = opt.CSS[0:n-4] + ".report.json"
+		}
+	}
+	if opt.Samples < 1 {
+		opt.Samples = 5
 	}
 	if 0 == len(opt.SkipAttr) {
 		opt.SkipAttr = []string{"style", "src", "href", "width", "height", "align", "border", "title", "alt"}
 	}
-
-	if selector, err = getCSSUsage(opt); nil == err {
-		if len(selector) > 1 {
-			cssContent = make([]string, 0, len(selector))
-
-			skipID = make(map[string]bool, len(opt.SkipID))
-			for _, sel4 = range opt.SkipID {
-				skipID[sel4] = true
-			}
-
-			skipClass = make(map[string]bool, len(opt.SkipClass))
-			for _, sel4 = range opt.SkipClass {
-				skipClass[sel4] = true
-			}
-
-			if cssInUse, err = getSourceUsage(opt); nil == err {
-				for _, css := range selector {
-					if "css" == css[0] {
-						findIt = false
-						sel1 = strings.Split(css[1], ",")
-
-						for _, sel4 = range sel1 {
-							selT = ""
-							sel2 = strings.Split(strings.Trim(sel4, "\r\n\t "), " ")
-
-							for _, sel5 = range sel2 {
-								if "" == sel5 {
-									continue
-								}
-
-								pair = strings.SplitN(sel5, ":", 2)
-								if sel3 = strings.Split(pair[0], "."); len(sel3) > 1 {
-									if skipClass[sel3[1]] {
-										continue
-									}
-
-									selT = "class"
-								} else if sel3 = strings.SplitN(pair[0], "#", 2); 2 == len(sel3) {
-									if skipID[sel3[1]] {
-										continue
-									}
-
-									selT = "id"
-								} else if sel3 = strings.SplitN(pair[0], "[", 2); 2 == len(sel3) {
-									selT = "attr"
-								} else {
-									selT = "tag"
-								}
-
-								break
-							}
-
-							if "class" == selT {
-								if "" == sel3[0] {
-									if cssInUse["class"][sel3[1]] > 0 {
-										findIt = true
-									}
-								} else {
-									tag = strings.ToLower(sel3[0])
-									if cssInUse["tag"][tag] > 0 && cssInUse["class"][sel3[1]] > 0 {
-										findIt = true
-									}
-								}
-							} else if "id" == selT {
-								if "" == sel3[0] {
-									if cssInUse["id"][sel3[1]] > 0 {
-										findIt = true
-									}
-								} else {
-									tag = strings.ToLower(sel3[0])
-									if cssInUse["tag"][tag] > 0 && cssInUse["id"][sel3[1]] > 0 {
-										findIt = true
-									}
-								}
-							} else if "attr" == selT {
-								if "" == sel3[0] {
-									findIt = true
-								} else {
-									tag = strings.ToLower(sel3[0])
-									if cssInUse["tag"][tag] > 0 && cssInUse["id"][sel3[1]] > 0 {
-										findIt = true
-									}
-								}
-							} else if "tag" == selT {
-								tag = strings.ToLower(sel3[0])
-								if cssInUse["tag"][tag] > 0 {
-									findIt = true
-								}
-							}
-
-							if findIt {
-								cssContent = append(cssContent, css[1]+" {"+opt.separator+css[2]+opt.separator+"}")
-
-								break
-							}
-						}
-					} else {
-						cssContent = append(cssContent, css[1])
-					}
-				}
-
-				if err = FilePutContents(opt.Output, []byte(strings.Join(cssContent, opt.separator+opt.separator)), false); nil == err && "" != opt.Summary {
-					if data, err = json.Marshal(cssInUse); nil == err {
-						err = FilePutContents(opt.Summary, data, false)
-					}
-				}
-			}
-		}
+	if nil != err {
+		return err
 	}
 
-	return err
-}
-
-// mergeDict 合并词典
-func mergeDict(cfg string) error {
-	var ok bool
-	var idx int
-	var err error
-	var exIdx int64
-	var element *Entry
-	var sDom, tDom *Dom
-	var sData, tData []byte
-	var targetContainer []string
-	var body, content, origin string
-	var targetEntriesMap map[string][]int
-	var sourceEntries, targetEntries []*Entry
-	var opt = new(MergeOption)
-	var tagSelOrigin = &TagSelector{Type: "class", Tag: "div", Attr: "class", Value: []string{"origin"}}
-	var tagSelEx = &TagSelector{Type: "class", Tag: "div", Attr: "class", Value: []string{"example"}}
-
-	if err = LoadJSON(cfg, opt); nil != err {
-		err = errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
-	}
-	if "" == opt.Source {
-		err = errors.New("词典源文件属性 Source 不能为空")
-	} else {
-		if _, err = os.Stat(opt.Source); nil != err {
-			err = errors.New("词典源文件 " + opt.Source + " 不存在")
-		}
+	// 源文件与 CSS 文件不是 UTF-8 编码时先转换为 UTF-8 的临时文件
+	if opt.Encoding, err = checkEncoding("Encoding", opt.Encoding); nil != err {
+		return err
 	}
-	if "" == opt.Target {
-		err = errors.New("词典目标文件属性 Target 不能为空")
-	} else {
-		if _, err = os.Stat(opt.Target); nil != err {
-			err = errors.New("词典目标文件 " + opt.Target + " 不存在")
-		}
+	if opt.Source, temp, err = decodeFile(opt.Source, opt.Encoding); nil != err {
+		return err
 	}
-	if "" == opt.Output {
-		opt.Output = opt.Target[0:len(opt.Target)-4] + ".new.txt"
+	if temp {
+		defer func(file string) {
+			_ = os.Remove(file)
+		}(opt.Source)
 	}
-
-	if sData, err = os.ReadFile(opt.Source); nil != err {
+	if opt.CSS, temp, err = decodeFile(opt.CSS, ""); nil != err {
 		return err
 	}
-	if tData, err = os.ReadFile(opt.Target); nil != err {
-		return err
+	if temp {
+		defer func(file string) {
+			_ = os.Remove(file)
+		}(opt.CSS)
 	}
 
-	sourceEntries = splitMdxData(sData)
-	targetEntries = splitMdxData(tData)
-	targetContainer = make([]string, len(targetEntries))
-	targetEntriesMap = make(map[string][]int, len(targetEntries))
+	if rules, err = getCSSUsage(opt); nil == err && len(rules) > 0 {
+		if cssInUse, err = getSourceUsage(opt); nil == err {
+			usage = newCSSUsage(opt, cssInUse, rules)
+			if err = usage.matchSource(); nil != err {
+				return err
+			}
 
-	for k, v := range targetEntries {
-		v.word = strings.ToLower(v.word)
-		if _, ok = targetEntriesMap[v.word]; !ok {
-			targetEntriesMap[v.word] = make([]int, 0, 5)
-		}
+			rules = filterCSSRules(rules, usage.used)
+			fmt.Println("rules:", usage.report.Rules, "kept:", usage.report.Kept, "unevaluated:", len(usage.report.Unevaluated))
 
-		targetContainer[k] = stripSpace(string(tData[v.start:v.end]))
-		targetEntriesMap[v.word] = append(targetEntriesMap[v.word], k)
-	}
-	for _, element = range sourceEntries {
-		body = ""
-		if "" != element.action {
-			continue
-		}
-
-		element.word = strings.ToLower(element.word)
-		if v, ok := targetEntriesMap[element.word]; ok {
-			for _, k1 := range v {
-				if k1 < len(targetEntries) {
-					if "" == targetEntries[k1].action {
-						idx = k1
-						body = targetContainer[k1]
+			cssContent = make([]string, 0, len(rules))
+			for _, rule := range rules {
+				cssContent = append(cssContent, rule.String("", opt.separator))
+			}
 
-						break
+			if err = FilePutContents(opt.Output, []byte(strings.Join(cssContent, opt.separator+opt.separator)), false); nil == err && "" != opt.Summary {
+				summary = &CSSSummary{Usage: cssInUse}
+				if summary.Unstyled, err = usage.unstyled(opt.Samples); nil == err {
+					fmt.Println("unstyled class:", len(summary.Unstyled.Class), "id:", len(summary.Unstyled.ID), "tag:", len(summary.Unstyled.Tag))
+				}
+				if nil == err {
+					if data, err = json.Marshal(summary); nil == err {
+						err = FilePutContents(opt.Summary, data, false)
 					}
-				} else {
-					idx = k1
-					body = targetContainer[k1]
-
-					break
 				}
 			}
-			if "" != body {
-				sDom = parseBodyItem(element, stripSpace(string(sData[element.start:element.end])))
-				origin = sDom.Find(tagSelOrigin).ToString(false)
-				tDom = parseBodyItem(element, body)
-				exIdx = tDom.Find(tagSelEx).GetSubIdx(0)
-
-				if "" != origin && exIdx > 0 {
-					tDom.Insert(origin, exIdx, true)
-
-					targetContainer[idx] = tDom.ToString(false)
-				}
+			if nil == err && "" != opt.Report {
+				err = usage.report.Save(opt.Report)
 			}
-		} else {
-			targetContainer = append(targetContainer, stripSpace(string(sData[element.start:element.end])))
-			targetEntriesMap[element.word] = []int{len(targetContainer) - 1}
 		}
 	}
 
-	content = strings.Join(targetContainer, "\r\n</>\r\n")
-
-	return FilePutContents(opt.Output, []byte(content), false)
+	return err
 }
 
 // CmdEntry 命令入口
@@ -1841,6 +1941,38 @@ func CmdEntry(entry string, cfg string) {
 		err = tidyCSS(cfg)
 	case "merge":
 		err = mergeDict(cfg)
+	case "unpack":
+		err = unpackMdict(cfg)
+	case "pack":
+		err = packMdict(cfg)
+	case "mdd-unpack":
+		err = unpackMdd(cfg)
+	case "mdd-pack":
+		err = packMdd(cfg)
+	case "links":
+		err = checkLinks(cfg)
+	case "lint":
+		err = lintMdict(cfg)
+	case "diff":
+		err = diffMdict(cfg)
+	case "serve":
+		err = serveMdict(cfg)
+	case "export-stardict":
+		err = exportStarDict(cfg)
+	case "import-stardict":
+		err = importStarDict(cfg)
+	case "import-dsl":
+		err = importDSL(cfg)
+	case "import-tsv":
+		err = importTSV(cfg)
+	case "variants":
+		err = generateVariants(cfg)
+	case "inflect":
+		err = generateInflections(cfg)
+	case "inline-style":
+		err = convertInlineStyles(cfg)
+	case "css-minify":
+		err = minifyCSS(cfg)
 	default:
 		err = errors.New("不支持的命令")
 	}
@@ -1849,6 +1981,7 @@ func CmdEntry(entry string, cfg string) {
 		fmt.Println("process done, use time", time.Now().Unix()-ts, err)
 	} else {
 		fmt.Println("process failed,", err)
+		os.Exit(1)
 	}
 }
 
@@ -1867,6 +2000,22 @@ func main() {
 		fmt.Fprintln(os.Stderr, "        tidy     词典源文件整理")
 		fmt.Fprintln(os.Stderr, "        css      词典引用的 CSS 整理")
 		fmt.Fprintln(os.Stderr, "        merge    合并两本词典")
+		fmt.Fprintln(os.Stderr, "        unpack   mdx 词典文件解包为源文件")
+		fmt.Fprintln(os.Stderr, "        pack     词典源文件打包为 mdx 词典")
+		fmt.Fprintln(os.Stderr, "        mdd-unpack  mdd 资源文件解包到目录")
+		fmt.Fprintln(os.Stderr, "        mdd-pack    目录打包为 mdd 资源文件")
+		fmt.Fprintln(os.Stderr, "        links    检查词典链接")
+		fmt.Fprintln(os.Stderr, "        lint     检查词典源文件并生成问题报告")
+		fmt.Fprintln(os.Stderr, "        diff     比较两个版本的词典源文件")
+		fmt.Fprintln(os.Stderr, "        serve    在浏览器中预览词典源文件")
+		fmt.Fprintln(os.Stderr, "        export-stardict  词典源文件导出为 StarDict 词典")
+		fmt.Fprintln(os.Stderr, "        import-stardict  StarDict 词典导入为源文件")
+		fmt.Fprintln(os.Stderr, "        import-dsl       Lingvo DSL 词典导入为源文件")
+		fmt.Fprintln(os.Stderr, "        import-tsv       制表符分隔的词汇表导入为源文件")
+		fmt.Fprintln(os.Stderr, "        variants 生成词头变体链接")
+		fmt.Fprintln(os.Stderr, "        inflect  生成英语屈折词形链接")
+		fmt.Fprintln(os.Stderr, "        inline-style  内联样式转换为 CSS 类")
+		fmt.Fprintln(os.Stderr, "        css-minify    压缩 CSS 文件并缩短类名")
 	}
 
 	flag.Parse()
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// sc2tcTable 内置的简繁汉字对照表
+//
+//go:embed sc2tc.txt
+var sc2tcTable string
+
+// variantRules 支持的变体规则，按此顺序依次作用于词头
+var variantRules = []string{"width", "case", "accent", "t2s", "s2t"}
+
+// VariantOption 词头变体链接生成选项
+type VariantOption struct {
+	LinksOnly bool          `label:"只输出生成的链接"`
+	Input     string        `label:"词典源文件"`
+	Output    string        `label:"输出的词典源文件"`
+	Report    string        `label:"冲突报告文件"`
+	Table     string        `label:"补充的简繁对照表"`
+	Rules     []string      `label:"变体规则"`
+	t2s       map[rune]rune `label:"繁体转简体对照"`
+	s2t       map[rune]rune `label:"简体转繁体对照"`
+}
+
+// VariantCollision 与已有词头或其它变体冲突而跳过的变体
+type VariantCollision struct {
+	Variant  string `label:"变体"`
+	Word     string `label:"生成变体的词头"`
+	Rule     string `label:"变体规则"`
+	Existing string `label:"冲突的词头"`
+	Reason   string `label:"冲突原因"`
+}
+
+// VariantReport 词头变体生成报告
+type VariantReport struct {
+	Rules      []string            `label:"变体规则"`
+	Words      int                 `label:"处理的词头数"`
+	Generated  int                 `label:"生成的链接数"`
+	Collisions []*VariantCollision `label:"冲突列表"`
+}
+
+// wordVariant 词头的一个变体
+type wordVariant struct {
+	word   string `label:"变体"`
+	rule   string `label:"变体规则"`
+	source string `label:"生成变体的词头"`
+	target string `label:"链接指向的词条"`
+}
+
+// Init 检查变体选项，加载简繁对照表
+func (o *VariantOption) Init() error {
+	var err error
+	var pos int
+	var data []byte
+	var msg = make([]string, 0, 2)
+	var valid = make(map[string]bool, len(variantRules))
+
+	if "" == o.Input {
+		return errors.New("输入文件属性 Input 不能为空")
+	}
+	if _, err = os.Stat(o.Input); nil != err {
+		return errors.New("输入文件 " + o.Input + " 不存在")
+	}
+
+	pos = len(o.Input) - len(filepath.Ext(o.Input))
+	if "" == o.Output {
+		o.Output = o.Input[:pos] + ".variants" + o.Input[pos:]
+	} else if o.Output == o.Input {
+		msg = append(msg, "输入文件和输出文件不能相同")
+	}
+	if "" == o.Report {
+		o.Report = o.Input[:pos] + ".variants.json"
+	}
+
+	for _, v := range variantRules {
+		valid[v] = true
+	}
+	if 0 == len(o.Rules) {
+		o.Rules = variantRules
+	}
+	for _, v := range o.Rules {
+		if !valid[v] {
+			msg = append(msg, "变体规则 Rules 只能是 "+strings.Join(variantRules, "、"))
+
+			break
+		}
+	}
+
+	o.t2s = make(map[rune]rune, 2000)
+	o.s2t = make(map[rune]rune, 2000)
+	o.loadTable(sc2tcTable)
+	if "" != o.Table {
+		if data, err = os.ReadFile(o.Table); nil == err {
+			o.loadTable(string(data))
+		} else {
+			msg = append(msg, "简繁对照表 "+o.Table+" 读取失败")
+		}
+	}
+
+	if len(msg) > 0 {
+		err = errors.New(strings.Join(msg, "\n"))
+	}
+
+	return err
+}
+
+// loadTable 加载简繁对照表，每行为一个简体字与对应的繁体字，对应多个繁体字的简体字不做简体转繁体
+func (o *VariantOption) loadTable(table string) {
+	var sc, tc []rune
+
+	for _, line := range strings.Split(table, "\n") {
+		if line = strings.TrimSpace(line); "" == line || '#' == line[0] {
+			continue
+		}
+		if pair := strings.Fields(line); 2 == len(pair) {
+			sc, tc = []rune(pair[0]), []rune(pair[1])
+			if 1 != len(sc) {
+				continue
+			}
+
+			delete(o.s2t, sc[0])
+			if 1 == len(tc) {
+				o.s2t[sc[0]] = tc[0]
+			}
+			for _, c := range tc {
+				if c != sc[0] {
+					o.t2s[c] = sc[0]
+				}
+			}
+		}
+	}
+}
+
+// apply 对词头执行一条变体规则
+func (o *VariantOption) apply(rule string, word string) string {
+	var table map[rune]rune
+
+	switch rule {
+	case "case":
+		return strings.ToLower(word)
+	case "width":
+		return width.Fold.String(word)
+	case "accent":
+		var ret, _, err = transform.String(transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), word)
+		if nil != err {
+			return word
+		}
+
+		return ret
+	case "t2s":
+		table = o.t2s
+	case "s2t":
+		table = o.s2t
+	}
+
+	return strings.Map(func(r rune) rune {
+		if v, ok := table[r]; ok {
+			return v
+		}
+
+		return r
+	}, word)
+}
+
+// variants 返回词头的全部变体，每条规则同时作用于原词头与前面规则生成的变体
+func (o *VariantOption) variants(word string) []*wordVariant {
+	var v, rule string
+	var seen = map[string]bool{word: true}
+	var ret = []*wordVariant{{word: word}}
+
+	for _, name := range o.Rules {
+		for _, item := range ret {
+			// 简体转繁体不作用于繁体转简体的结果，避免生成简繁混杂的词头
+			if "s2t" == name && strings.Contains(item.rule, "t2s") {
+				continue
+			}
+			if v = o.apply(name, item.word); seen[v] || "" == strings.TrimSpace(v) {
+				continue
+			}
+
+			if rule = name; "" != item.rule {
+				rule = item.rule + "+" + name
+			}
+
+			seen[v] = true
+			ret = append(ret, &wordVariant{word: v, rule: rule})
+		}
+	}
+
+	return ret[1:]
+}
+
+// Save 保存变体冲突报告
+func (r *VariantReport) Save(file string) error {
+	var data, err = json.MarshalIndent(r, "", "    ")
+	if nil != err {
+		return err
+	}
+
+	return FilePutContents(file, data, false)
+}
+
+// generateVariants 为词头生成大小写、去除重音、全半角与简繁变体的链接
+//
+// 实现思路：
+//
+//	1、第一遍读取全部词头与链接，沿链接找到最终指向的词条，链接词头的变体指向最终的词条
+//	2、第二遍按源文件顺序复制词条，同时按规则生成每个词头的变体
+//	3、变体与已有的词头相同，或与其它词头生成的指向不同词条的变体相同时跳过并记入冲突报告
+//	4、生成的 @@@LINK 词条追加到输出文件末尾，冲突报告保存为 JSON
+func generateVariants(cfg string) error {
+	var num int
+	var err error
+	var chunk []byte
+	var target string
+	var element *Entry
+	var fp *os.File
+	var buf *bufio.Writer
+	var reader *EntryReader
+	var links *LinkReport
+	var words map[string]bool
+	var targets map[string]string
+	var opt = new(VariantOption)
+	var done = make(map[string]bool, 100000)
+	var generated = make(map[string]*wordVariant, 100000)
+	var list = make([]*wordVariant, 0, 100000)
+	var report = &VariantReport{Collisions: make([]*VariantCollision, 0, 100)}
+
+	if err = LoadJSON(cfg, opt); nil != err {
+		return errors.New("加载配置文件 " + cfg + " 失败，" + err.Error())
+	}
+	if err = opt.Init(); nil != err {
+		return errors.New("检查配置文件 " + cfg + " 失败，" + err.Error())
+	}
+
+	if words, targets, err = scanWordLinks(opt.Input, func(chunk []byte, offset int64) *Entry {
+		return parseBody(chunk, 0, len(chunk))
+	}); nil != err {
+		return err
+	}
+
+	links = analyzeLinks(words, targets)
+	report.Rules = opt.Rules
+
+	if reader, err = OpenEntryReader(opt.Input); nil != err {
+		return err
+	}
+	defer reader.Close()
+
+	if fp, err = os.OpenFile(opt.Output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm); nil != err {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	buf = bufio.NewWriterSize(fp, 1<<20)
+	for {
+		if chunk, _, err = reader.Next(); nil != err {
+			break
+		}
+		if !opt.LinksOnly {
+			if num > 0 {
+				buf.WriteString("\r\n</>\r\n")
+			}
+
+			num++
+			buf.Write(chunk)
+		}
+		if element = parseBody(chunk, 0, len(chunk)); nil == element || done[element.word] {
+			continue
+		}
+
+		done[element.word] = true
+		if target = element.word; "link" == strings.ToLower(element.action) {
+			if target = links.final[element.word]; "" == target {
+				continue
+			}
+		}
+
+		report.Words++
+		for _, v := range opt.variants(element.word) {
+			if v.word == target {
+				continue
+			}
+			if words[v.word] || "" != targets[v.word] {
+				report.Collisions = append(report.Collisions, &VariantCollision{Variant: v.word, Word: element.word, Rule: v.rule, Existing: v.word, Reason: "headword"})
+
+				continue
+			}
+			if first, ok := generated[v.word]; ok {
+				if first.target != target {
+					report.Collisions = append(report.Collisions, &VariantCollision{Variant: v.word, Word: element.word, Rule: v.rule, Existing: first.source, Reason: "variant"})
+				}
+
+				continue
+			}
+
+			v.source, v.target = element.word, target
+			generated[v.word] = v
+			list = append(list, v)
+		}
+	}
+	if io.EOF != err {
+		return err
+	}
+
+	for _, v := range list {
+		if num > 0 {
+			buf.WriteString("\r\n</>\r\n")
+		}
+
+		num++
+		buf.WriteString(v.word + "\r\n@@@LINK=" + v.target)
+	}
+	if err = buf.Flush(); nil != err {
+		return err
+	}
+
+	report.Generated = len(list)
+	fmt.Println("words:", report.Words, "variants:", report.Generated, "collisions:", len(report.Collisions))
+
+	return report.Save(opt.Report)
+}
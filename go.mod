@@ -1,3 +1,5 @@
 module github.com/csg2008/tools
 
 go 1.18
+
+require golang.org/x/text v0.14.0